@@ -5,7 +5,9 @@ go 1.24.4
 require (
 	github.com/Microsoft/go-winio v0.6.2
 	github.com/gdamore/tcell/v2 v2.8.1
+	github.com/pelletier/go-toml/v2 v2.2.4
 	github.com/rivo/tview v0.0.0-20250501113434-0c592cd31026
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
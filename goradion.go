@@ -4,17 +4,41 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/agejevasv/goradion/internal/radio"
 )
 
-var cfg = flag.String("s", "", "A link or a path to a stations.csv file")
+var cfg sources
 var ver = flag.Bool("v", false, "Show the version number and quit")
 var dbg = flag.Bool("d", false, "Enable debug log (goradion.log file in a current dir)")
-var chk = flag.Bool("c", false, "")
+var chk = flag.Bool("c", false, "Check the stations, exit with 1 when some of them are dead")
+var jobs = flag.Int("j", 16, "The number of stations -c checks at once")
+var wait = flag.Duration("w", 10*time.Second, "How long -c waits for a station")
+var format = flag.String("f", "text", fmt.Sprintf("The report format of -c, one of %v", radio.CheckFormats))
+var bnd = flag.String("b", "mpv", fmt.Sprintf("Audio backend, one of %v", radio.Backends))
+var rec = flag.Bool("r", false, "Record every played station (toggle with Ctrl+W)")
+var out = flag.String("o", ".", "A directory for recordings")
+var split = flag.Bool("t", false, "Split recordings into a file per track")
+var rbu = flag.String("u", "", "A radio-browser.info API URL to use instead of the public mirrors, e.g. a self-hosted one")
+var exp = flag.String("e", "", fmt.Sprintf("Export liked songs (Ctrl+L) as one of %v and quit", radio.ExportFormats))
 
+// sources collects the values of a repeated flag.
+type sources []string
+
+func (s *sources) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *sources) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
 
 func main() {
+	flag.Var(&cfg, "s", fmt.Sprintf("A link or a path to a stations file (CSV, JSON, TOML or YAML), "+
+		"repeat it to merge several, %q stands for the built-in stations", radio.DefaultSource))
 	flag.Parse()
 
 	if *ver {
@@ -24,22 +48,60 @@ func main() {
 
 	radio.InitLog(*dbg)
 
-	stations := radio.Stations(*cfg)
+	if *rbu != "" {
+		radio.SetRadioBrowserURL(*rbu)
+	}
+
+	if *exp != "" {
+		if err := radio.NewLikedSongs().Export(os.Stdout, *exp); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	stations, notes := radio.Stations(cfg...)
 	if len(stations) == 0 {
 		fmt.Println("Stations list is empty, exiting.")
 		os.Exit(0)
 	}
 
 	if *chk {
-		radio.CheckStations(stations)
+		for _, note := range notes {
+			fmt.Fprintln(os.Stderr, note)
+		}
+
+		dead, err := radio.CheckStations(os.Stdout, stations, radio.CheckOptions{
+			Workers: *jobs,
+			Timeout: *wait,
+			Format:  *format,
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if dead > 0 {
+			os.Exit(1)
+		}
 		os.Exit(0)
 	}
 
-	player := radio.NewPlayer()
+	backend, err := radio.NewBackend(*bnd)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	player := radio.NewPlayer(backend)
+	player.SetRecording(*out, *rec, *split)
 	go player.Start()
 	defer player.Quit()
 
-	if err := radio.NewApp(player, stations).Run(); err != nil {
+	app := radio.NewApp(player, stations)
+	app.ShowNotes(notes)
+	app.SetSources(cfg)
+
+	if err := app.Run(); err != nil {
 		panic(err)
 	}
 }
@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"net/url"
 	"regexp"
 	"slices"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
@@ -35,7 +37,8 @@ const (
 		Show search to find stations.
 
 	[green]Ctrl+S[-]
-		Search online via radio-browser.info.
+		Search or browse online via radio-browser.info, press Ctrl+O to filter the
+		search, Backspace to go back and Ctrl+A on a result to save it.
 
 	[green]Ctrl+R[-]
 		Toggle shuffle mode (plays a random station at timed intervals).
@@ -43,6 +46,27 @@ const (
 	[green]Alt+1[-] to [green]Alt+9[-]
 		Set shuffle interval to 1-9 minutes and reset timer.
 
+	[green]Ctrl+W[-]
+		Start/stop recording the current station to a file.
+
+	[green]Ctrl+L[-]
+		Like the current song (export liked songs with -e).
+
+	[green]Ctrl+V[-]
+		Vote for the selected (or playing) radio-browser.info station.
+
+	[green]Ctrl+D[-]
+		Show details of the selected (or playing) station, with stream diagnostics.
+
+	[green]Ctrl+Y[-]
+		Show recently played songs, press Enter to play the station again.
+
+	[green]Ctrl+N[-]
+		Add a station to the stations file.
+
+	[green]Ctrl+E[-] and [green]Delete[-]
+		Edit or delete the selected station in the stations file.
+
 	[green]Enter[-] and [green]Space[-]
 		Toggle playing currently selected station.
 
@@ -70,6 +94,11 @@ const (
 	Tags
 	Search
 	Browse
+	History
+	Details
+	Editor
+	Confirm
+	Filters
 )
 
 type Application struct {
@@ -88,12 +117,27 @@ type Application struct {
 	status                  *tview.TextView
 	volume                  *tview.TextView
 	favorites               *Favorites
+	history                 *SongHistory
+	liked                   *LikedSongs
+	historyFilter           *tview.InputField
+	historyList             *tview.List
+	details                 *tview.TextView
+	detailsShown            int
+	editor                  *tview.Form
+	confirm                 *tview.Modal
+	sources                 []string
+	listedStations          []Station
 	searchModal             *tview.Flex
 	searchInput             *tview.InputField
 	searchResults           *tview.List
 	browseModal             *tview.Flex
 	browseInput             *tview.InputField
 	browseResults           *tview.List
+	browseFound             []Station
+	browseBack              func()
+	browseQuery             RadioBrowserQuery
+	browseFilters           *tview.Form
+	browseFiltersText       *tview.TextView
 	lastBrowseStations      []Station
 	timedRandomActive       bool
 	timedRandomCancel       context.CancelFunc
@@ -101,20 +145,41 @@ type Application struct {
 	shuffleInterval         time.Duration
 	waitingForPlayback      chan struct{}
 	waitingForURL           string
+	lastInfo                Info
+	statusMu                sync.Mutex
+	nextInfo                Info          // the info to render next, guarded by statusMu
+	songChanged             bool          // guarded by statusMu
+	statusChanged           chan struct{} // signals renderStatusUpdates, never blocks
+	stopped                 chan struct{}
 }
 
 func NewApp(player *Player, stations []Station) *Application {
+	return newApp(player, stations, nil)
+}
+
+// newApp binds the application to the given screen, or to the terminal when
+// the screen is nil.
+func newApp(player *Player, stations []Station, screen tcell.Screen) *Application {
 	a := &Application{
 		player:          player,
 		stations:        stations,
-		pageNames:       []string{"Main", "Help", "Tags", "Search", "Browse"},
+		pageNames:       []string{"Main", "Help", "Tags", "Search", "Browse", "History", "Details", "Editor", "Confirm", "Filters"},
 		favorites:       NewFavorites(stations),
+		history:         NewSongHistory(),
+		liked:           NewLikedSongs(),
 		shuffleInterval: 5 * time.Minute,
+		statusChanged:   make(chan struct{}, 1),
+		stopped:         make(chan struct{}),
 	}
 
+	player.SetHistory(a.history)
+
 	a.setupPages()
 	a.setupSearchModal()
 	a.setupBrowseModal()
+	a.setupHistoryPage()
+	a.setupDetailsPage()
+	a.setupEditor()
 
 	a.app = tview.NewApplication().
 		SetRoot(a.pages, true).
@@ -122,17 +187,32 @@ func NewApp(player *Player, stations []Station) *Application {
 		SetMouseCapture(devNullMouse()).
 		SetInputCapture(a.inputCapture())
 
+	if screen != nil {
+		a.app.SetScreen(screen)
+	}
+
 	go a.updateStatus()
+	go a.renderStatusUpdates()
+	go a.updateRecordingStatus()
 
 	return a
 }
 
+// ShowNotes shows the notes in the status bar instead of the greeting.
+func (a *Application) ShowNotes(notes []string) {
+	if len(notes) > 0 {
+		a.status.SetText("[yellow]" + stripBraces(strings.Join(notes, ", ")))
+	}
+}
+
 func (a *Application) Run() error {
+	defer close(a.stopped)
 	return a.app.Run()
 }
 
 func (a *Application) setupPages() {
-	a.stationsList = a.setupStationsList(newList(), a.stations)
+	a.stationsList = newList()
+	a.setupStationsList(a.stationsList, a.stations)
 	a.tagsList = a.setupTagsList()
 
 	a.status = tview.NewTextView().
@@ -145,9 +225,7 @@ func (a *Application) setupPages() {
 		SetTextColor(tcell.ColorLightGray).
 		SetTextAlign(tview.AlignRight)
 
-	statusFlex := tview.NewFlex().SetDirection(tview.FlexColumn).
-		AddItem(a.status, 0, 100, true).
-		AddItem(a.volume, 0, 25, false)
+	statusFlex := a.newStatusFlex()
 
 	a.mainFlex = tview.NewFlex().
 		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
@@ -181,14 +259,21 @@ func (a *Application) show(page Page) {
 	a.pages.SwitchToPage(a.pageNames[page])
 }
 
+// inputEditingKeys are the keys the text inputs edit with, they are not
+// taken by the global shortcuts while an input has the focus.
+var inputEditingKeys = []tcell.Key{
+	tcell.KeyDelete, tcell.KeyCtrlD, tcell.KeyCtrlW, tcell.KeyCtrlA,
+	tcell.KeyCtrlE, tcell.KeyCtrlK, tcell.KeyCtrlU,
+}
+
 func (a *Application) inputCapture() func(event *tcell.EventKey) *tcell.EventKey {
 	return func(event *tcell.EventKey) *tcell.EventKey {
 		var currentPage = a.pages.GetPageNames(true)[0]
 
-		closeHelp := func() bool {
-			if currentPage == a.pageNames[Help] && len(a.pageHistory) > 1 {
+		closePage := func(page Page) bool {
+			if currentPage == a.pageNames[page] && len(a.pageHistory) > 1 {
 				previous := a.pageHistory[len(a.pageHistory)-2]
-				if previous != Help {
+				if previous != page {
 					a.show(previous)
 					return true
 				}
@@ -196,6 +281,26 @@ func (a *Application) inputCapture() func(event *tcell.EventKey) *tcell.EventKey
 			return false
 		}
 
+		closeHelp := func() bool {
+			return closePage(Help)
+		}
+
+		// the forms and the confirmation take all the keys
+		switch front, _ := a.pages.GetFrontPage(); front {
+		case a.pageNames[Editor], a.pageNames[Confirm], a.pageNames[Filters]:
+			return event
+		}
+
+		// the history filter takes all the characters
+		if event.Key() == tcell.KeyRune && a.app.GetFocus() == a.historyFilter {
+			return event
+		}
+
+		// the text inputs keep their editing keys
+		if _, ok := a.app.GetFocus().(*tview.InputField); ok && slices.Contains(inputEditingKeys, event.Key()) {
+			return event
+		}
+
 		switch key := event.Key(); key {
 		case tcell.KeyEscape:
 			if currentPage == a.pageNames[Search] || currentPage == a.pageNames[Browse] {
@@ -213,10 +318,38 @@ func (a *Application) inputCapture() func(event *tcell.EventKey) *tcell.EventKey
 				return nil
 			}
 
-			if !closeHelp() {
+			if !closeHelp() && !closePage(History) && !closePage(Details) {
 				a.show(Tags)
 			}
 			return nil
+		case tcell.KeyCtrlL:
+			a.likeSong()
+			return nil
+		case tcell.KeyCtrlD:
+			if !closePage(Details) {
+				a.showDetails()
+			}
+			return nil
+		case tcell.KeyCtrlY:
+			a.showHistory()
+			return nil
+		case tcell.KeyCtrlV:
+			a.voteStation()
+			return nil
+		case tcell.KeyCtrlN:
+			a.showEditor(nil)
+			return nil
+		case tcell.KeyCtrlE, tcell.KeyDelete:
+			s, ok := a.selectedStation()
+			if !ok {
+				return event
+			}
+			if key == tcell.KeyDelete {
+				a.confirmDelete(s)
+			} else {
+				a.showEditor(&s)
+			}
+			return nil
 		case tcell.KeyCtrlF:
 			a.showSearchModal()
 			return nil
@@ -226,6 +359,9 @@ func (a *Application) inputCapture() func(event *tcell.EventKey) *tcell.EventKey
 		case tcell.KeyCtrlR:
 			go a.toggleTimedRandom()
 			return nil
+		case tcell.KeyCtrlW:
+			go a.toggleRecording()
+			return nil
 		case tcell.KeyLeft:
 			a.player.VolumeDn()
 			return nil
@@ -270,30 +406,123 @@ func (a *Application) inputCapture() func(event *tcell.EventKey) *tcell.EventKey
 	}
 }
 
+// updateStatus keeps the latest info of the player for renderStatusUpdates.
+// It never waits for the UI goroutine, which may be waiting for the player.
 func (a *Application) updateStatus() {
 	for inf := range a.player.Info {
-		stationName := stripPlayCount(inf.Station)
-		if inf.Song == "" && inf.Status == "" {
-			a.status.SetText(stationName)
-		} else if inf.Song == "" {
-			a.status.SetText(fmt.Sprintf("%s [gray]| [green]%s", stationName, inf.Status))
-		} else {
-			a.status.SetText(fmt.Sprintf("%s [gray]| [green]%s", stationName, stripBraces(inf.Song)))
+		a.statusMu.Lock()
+		if inf.Song != "" && inf.Song != a.nextInfo.Song {
+			a.songChanged = true
 		}
+		a.nextInfo = inf
+		a.statusMu.Unlock()
 
-		if inf.Bitrate > 0 {
-			a.volume.SetText(fmt.Sprintf("%d kb/s [gray]|[lightgray] %d%%", inf.Bitrate, inf.Volume))
-		} else {
-			a.volume.SetText(fmt.Sprintf("%d%%", inf.Volume))
-		}
+		a.statusUpdated()
 
 		if a.waitingForPlayback != nil && inf.Url == a.waitingForURL && (inf.Status == "Playing" || inf.Song != "") {
 			close(a.waitingForPlayback)
 			a.waitingForPlayback = nil
 			a.waitingForURL = ""
 		}
+	}
+}
 
-		a.app.Draw()
+// statusUpdated asks for the status to be rendered, the requests made while
+// one is pending are rendered together.
+func (a *Application) statusUpdated() {
+	select {
+	case a.statusChanged <- struct{}{}:
+	default:
+	}
+}
+
+// renderStatusUpdates renders the latest info of the player on the UI
+// goroutine until the application stops.
+func (a *Application) renderStatusUpdates() {
+	for {
+		select {
+		case <-a.stopped:
+			return
+		case <-a.statusChanged:
+		}
+
+		a.app.QueueUpdateDraw(func() {
+			a.statusMu.Lock()
+			inf, songChanged := a.nextInfo, a.songChanged
+			a.songChanged = false
+			a.statusMu.Unlock()
+
+			a.lastInfo = inf
+			a.renderStatus()
+
+			if songChanged {
+				a.refreshHistory()
+			}
+		})
+	}
+}
+
+// renderStatus must be called on the UI goroutine.
+func (a *Application) renderStatus() {
+	inf := a.lastInfo
+	stationName := stripPlayCount(inf.Station)
+	status := ""
+
+	if inf.Song == "" && inf.Status == "" {
+		status = stationName
+	} else if inf.Song == "" {
+		status = fmt.Sprintf("%s [gray]| [green]%s", stationName, inf.Status)
+	} else {
+		status = fmt.Sprintf("%s [gray]| [green]%s", stationName, stripBraces(inf.Song))
+	}
+
+	if inf.Mirrors > 1 {
+		status += fmt.Sprintf(" [gray]| mirror %d/%d", inf.Mirror, inf.Mirrors)
+		if u, err := url.Parse(inf.Stream); err == nil {
+			status += fmt.Sprintf(" (%s)", u.Host)
+		}
+	}
+
+	if inf.Recording != nil {
+		status += fmt.Sprintf(" [gray]| [red]● REC[-] [lightgray]%s", inf.Recording)
+	}
+
+	a.status.SetText(status)
+
+	if inf.Bitrate > 0 {
+		a.volume.SetText(fmt.Sprintf("%d kb/s [gray]|[lightgray] %d%%", inf.Bitrate, inf.Volume))
+	} else {
+		a.volume.SetText(fmt.Sprintf("%d%%", inf.Volume))
+	}
+}
+
+// updateRecordingStatus refreshes the elapsed time and size of a recording.
+func (a *Application) updateRecordingStatus() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stopped:
+			return
+		case <-ticker.C:
+		}
+
+		a.statusMu.Lock()
+		recording := a.nextInfo.Recording != nil
+		a.statusMu.Unlock()
+
+		if recording {
+			a.statusUpdated()
+		}
+	}
+}
+
+func (a *Application) toggleRecording() {
+	if err := a.player.ToggleRecording(); err != nil {
+		a.app.QueueUpdateDraw(func() {
+			a.status.SetText(fmt.Sprintf("[red]Can't record: %s", err))
+		})
 	}
 }
 
@@ -301,6 +530,10 @@ func (a *Application) setupStationsList(list *tview.List, stations []Station) *t
 	list.Clear()
 	list.SetCurrentItem(0)
 
+	if list == a.stationsList {
+		a.listedStations = stations
+	}
+
 	offset := a.calculateStationListOffset()
 
 	if a.tag != "" {
@@ -379,7 +612,9 @@ func (a *Application) setupTagsList() *tview.List {
 }
 
 func (a *Application) togglePlay(station Station) {
-	if station.url != "" && station.url != a.player.info.Url {
+	starting := station.url != "" && station.url != a.player.info.Url
+
+	if starting {
 		a.favorites.track(station)
 		if a.tag == favoritesTag {
 			a.filterStationsForSelectedTag()
@@ -387,6 +622,14 @@ func (a *Application) togglePlay(station Station) {
 		}
 	}
 	a.player.Toggle(station)
+
+	if starting && station.uuid != "" {
+		go func() {
+			if err := radioBrowserClick(station.uuid); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
 }
 
 func (a *Application) togglePlayManual(station Station) {
@@ -476,14 +719,18 @@ func (a *Application) findStationIndex(stationURL string, stations []Station) in
 func (a *Application) refreshTagsPage() {
 	a.tagsList = a.setupTagsList()
 	a.tagsFlex.Clear()
-	statusFlex := tview.NewFlex().SetDirection(tview.FlexColumn).
-		AddItem(a.status, 0, 100, true).
-		AddItem(a.volume, 0, 25, false)
+	statusFlex := a.newStatusFlex()
 	a.tagsFlex.AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(a.tagsList, 0, 100, true).
 		AddItem(statusFlex, 0, 1, true), 0, 1, true)
 }
 
+func (a *Application) newStatusFlex() *tview.Flex {
+	return tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(a.status, 0, 100, true).
+		AddItem(a.volume, 0, 25, false)
+}
+
 func newList() *tview.List {
 	list := tview.NewList()
 	list.ShowSecondaryText(false)
@@ -0,0 +1,649 @@
+package radio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+var testStations = []Station{
+	{title: "Jazz One", url: "http://jazz.one/stream", tags: []string{"Jazz"}},
+	{title: "Jazz Two", url: "http://jazz.two/stream", tags: []string{"Jazz", "Lounge"}},
+	{title: "Rock Radio", url: "http://rock.radio/stream", tags: []string{"Rock"}},
+}
+
+// testBackend records what the player asks it to do and plays nothing.
+type testBackend struct {
+	sync.Mutex
+	loaded []string
+	volume int
+	events chan Event
+}
+
+func newTestBackend() *testBackend {
+	return &testBackend{volume: defaultVolume, events: make(chan Event)}
+}
+
+func (b *testBackend) Start() error             { return nil }
+func (b *testBackend) Stop() error              { return nil }
+func (b *testBackend) Quit() error              { return nil }
+func (b *testBackend) Record(path string) error { return nil }
+
+func (b *testBackend) Events() <-chan Event {
+	return b.events
+}
+
+func (b *testBackend) Load(url string) error {
+	b.Lock()
+	defer b.Unlock()
+	b.loaded = append(b.loaded, url)
+	return nil
+}
+
+func (b *testBackend) SetVolume(volume int) error {
+	b.Lock()
+	defer b.Unlock()
+	b.volume = volume
+	return nil
+}
+
+func (b *testBackend) lastLoaded() string {
+	b.Lock()
+	defer b.Unlock()
+	if len(b.loaded) == 0 {
+		return ""
+	}
+	return b.loaded[len(b.loaded)-1]
+}
+
+// tuiHarness runs the application on a simulated screen.
+type tuiHarness struct {
+	t       *testing.T
+	app     *Application
+	screen  tcell.SimulationScreen
+	backend *testBackend
+	done    chan error
+}
+
+func newTUIHarness(t *testing.T, stations []Station) *tuiHarness {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	screen := tcell.NewSimulationScreen("UTF-8")
+	backend := newTestBackend()
+	player := NewPlayer(backend)
+	player.Start()
+
+	h := &tuiHarness{
+		t:       t,
+		app:     newApp(player, stations, screen),
+		screen:  screen,
+		backend: backend,
+		done:    make(chan error, 1),
+	}
+	screen.SetSize(80, 30)
+
+	go func() {
+		h.done <- h.app.Run()
+	}()
+
+	t.Cleanup(func() {
+		h.app.app.Stop()
+	})
+
+	h.waitForText("Ready")
+
+	return h
+}
+
+func (h *tuiHarness) key(k tcell.Key) {
+	h.screen.InjectKey(k, 0, tcell.ModNone)
+}
+
+func (h *tuiHarness) rune(r rune) {
+	h.screen.InjectKey(tcell.KeyRune, r, tcell.ModNone)
+}
+
+func (h *tuiHarness) typeText(s string) {
+	for _, r := range s {
+		h.rune(r)
+	}
+}
+
+// text returns the rendered screen, one line per row. The cells are shared
+// with the screen, so they are read on the UI goroutine that draws them.
+func (h *tuiHarness) text() string {
+	var sb strings.Builder
+
+	h.app.app.QueueUpdate(func() {
+		cells, width, _ := h.screen.GetContents()
+
+		for i, c := range cells {
+			if len(c.Runes) > 0 {
+				sb.WriteRune(c.Runes[0])
+			} else {
+				sb.WriteRune(' ')
+			}
+			if (i+1)%width == 0 {
+				sb.WriteRune('\n')
+			}
+		}
+	})
+
+	return sb.String()
+}
+
+// eventually fails the test with the given description, which is rendered
+// at the time of failure, unless the condition becomes true in time.
+func (h *tuiHarness) eventually(cond func() bool, describe func() string) {
+	h.t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+
+	for !cond() {
+		if time.Now().After(deadline) {
+			h.t.Fatal(describe())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (h *tuiHarness) waitForText(s string) {
+	h.t.Helper()
+	h.eventually(func() bool {
+		return strings.Contains(h.text(), s)
+	}, func() string {
+		return fmt.Sprintf("%q is not on the screen:\n%s", s, h.text())
+	})
+}
+
+func (h *tuiHarness) waitForNoText(s string) {
+	h.t.Helper()
+	h.eventually(func() bool {
+		return !strings.Contains(h.text(), s)
+	}, func() string {
+		return fmt.Sprintf("%q is still on the screen:\n%s", s, h.text())
+	})
+}
+
+// page returns the name of the front page, read on the UI goroutine.
+func (h *tuiHarness) page() string {
+	ch := make(chan string, 1)
+	h.app.app.QueueUpdate(func() {
+		name, _ := h.app.pages.GetFrontPage()
+		ch <- name
+	})
+	return <-ch
+}
+
+func (h *tuiHarness) waitForPage(page Page) {
+	h.t.Helper()
+	want := h.app.pageNames[page]
+	h.eventually(func() bool {
+		return h.page() == want
+	}, func() string {
+		return fmt.Sprintf("front page is %s, want %s:\n%s", h.page(), want, h.text())
+	})
+}
+
+func TestTUIStartsOnTags(t *testing.T) {
+	h := newTUIHarness(t, testStations)
+
+	h.waitForPage(Tags)
+	for _, tag := range tags(testStations) {
+		h.waitForText(tag)
+	}
+}
+
+func TestTUISelectTagAndEsc(t *testing.T) {
+	h := newTUIHarness(t, testStations)
+
+	// Tags are sorted: Jazz, Lounge, Rock.
+	h.rune('a')
+	h.waitForPage(Main)
+	h.waitForText("Jazz One")
+	h.waitForText("Jazz Two")
+	h.waitForNoText("Rock Radio")
+
+	h.key(tcell.KeyEscape)
+	h.waitForPage(Tags)
+	if h.app.tag != "" {
+		t.Errorf("tag = %q after Esc, want it cleared", h.app.tag)
+	}
+
+	h.key(tcell.KeyEscape)
+	select {
+	case err := <-h.done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Esc on the tags page didn't quit")
+	}
+}
+
+func TestTUIHelpReturnsToPreviousPage(t *testing.T) {
+	h := newTUIHarness(t, testStations)
+
+	h.rune('~')
+	h.waitForPage(Main)
+
+	h.rune('?')
+	h.waitForPage(Help)
+	h.waitForText("Keyboard Control")
+
+	h.rune('?')
+	h.waitForPage(Main)
+
+	h.rune('?')
+	h.waitForPage(Help)
+
+	h.key(tcell.KeyEscape)
+	h.waitForPage(Main)
+
+	if !slices.Equal(h.app.pageHistory, []Page{Help, Main}) {
+		t.Errorf("page history = %v", h.app.pageHistory)
+	}
+}
+
+func TestTUIPlayStation(t *testing.T) {
+	h := newTUIHarness(t, testStations)
+
+	h.rune('~')
+	h.waitForPage(Main)
+
+	h.rune('b')
+	h.waitForText("Jazz Two | " + buffering)
+
+	h.eventually(func() bool {
+		return h.backend.lastLoaded() == testStations[1].url
+	}, func() string {
+		return fmt.Sprintf("backend loaded %q, want %q", h.backend.lastLoaded(), testStations[1].url)
+	})
+
+	h.backend.events <- Event{Kind: EventPlaying}
+	h.waitForText("Jazz Two | " + playing)
+
+	h.key(tcell.KeyRight)
+	h.waitForText("85%")
+}
+
+func TestTUIVolumeWhilePlaying(t *testing.T) {
+	h := newTUIHarness(t, testStations)
+
+	h.rune('~')
+	h.waitForPage(Main)
+	h.rune('a')
+	h.waitForText("Jazz One | " + buffering)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 200 {
+			h.backend.events <- Event{Kind: EventBitrate, Bitrate: i + 1}
+		}
+	}()
+
+	for range 100 {
+		h.key(tcell.KeyRight)
+		h.key(tcell.KeyLeft)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the player is stuck")
+	}
+
+	h.backend.events <- Event{Kind: EventBitrate, Bitrate: 320}
+	h.waitForText("320 kb/s | 80%")
+}
+
+func TestTUISearch(t *testing.T) {
+	h := newTUIHarness(t, testStations)
+
+	h.key(tcell.KeyCtrlF)
+	h.waitForPage(Search)
+	h.waitForText("Station Search")
+
+	h.typeText("rock")
+	h.waitForText("Rock Radio")
+	h.waitForNoText("Jazz One")
+
+	// the input keeps its editing keys
+	h.key(tcell.KeyCtrlW)
+	h.typeText("jazz")
+	h.waitForText("Jazz One")
+	h.key(tcell.KeyCtrlA)
+	h.key(tcell.KeyDelete)
+	h.waitForText("Search: azz")
+	if h.page() != h.app.pageNames[Search] || strings.Contains(h.text(), "Can't record") {
+		t.Errorf("the editing keys were taken by the shortcuts")
+	}
+
+	h.key(tcell.KeyCtrlU)
+	h.typeText("rock")
+	h.waitForText("Rock Radio")
+
+	h.key(tcell.KeyEnter)
+	h.waitForPage(Main)
+	h.waitForText("rock")
+	h.waitForText("Rock Radio")
+
+	h.key(tcell.KeyCtrlF)
+	h.waitForText("Station Search")
+	h.key(tcell.KeyEscape)
+	h.waitForNoText("Station Search")
+	h.waitForPage(Main)
+}
+
+func TestTUIShuffleBorder(t *testing.T) {
+	h := newTUIHarness(t, testStations)
+
+	h.rune('~')
+	h.waitForPage(Main)
+
+	h.key(tcell.KeyCtrlR)
+	h.waitForText("Shuffle 0")
+
+	h.key(tcell.KeyCtrlR)
+	h.waitForNoText("Shuffle")
+}
+
+func TestTUIHistory(t *testing.T) {
+	h := newTUIHarness(t, testStations)
+
+	h.app.history.add(HistoryEntry{Time: time.Now(), Station: "Rock Radio", URL: testStations[2].url, Song: "Old - Song"})
+
+	h.rune('~')
+	h.waitForPage(Main)
+	h.rune('a')
+	h.waitForText("Jazz One | " + buffering)
+	h.backend.events <- Event{Kind: EventMetadata, Metadata: map[string]any{"icy-title": "New - Song"}}
+	h.waitForText("Jazz One | New - Song")
+
+	h.key(tcell.KeyCtrlY)
+	h.waitForPage(History)
+	h.waitForText("Jazz One | New - Song")
+	h.waitForText("Rock Radio | Old - Song")
+
+	h.typeText("rock")
+	h.waitForText("Rock Radio | Old - Song")
+	h.eventually(func() bool {
+		// only in the status bar
+		return strings.Count(h.text(), "New - Song") == 1
+	}, func() string {
+		return fmt.Sprintf("history isn't filtered:\n%s", h.text())
+	})
+
+	h.key(tcell.KeyDown)
+	h.key(tcell.KeyEnter)
+	h.eventually(func() bool {
+		return h.backend.lastLoaded() == testStations[2].url
+	}, func() string {
+		return fmt.Sprintf("backend loaded %q, want %q", h.backend.lastLoaded(), testStations[2].url)
+	})
+
+	h.key(tcell.KeyEscape)
+	h.waitForPage(Main)
+}
+
+func TestTUILikeSong(t *testing.T) {
+	h := newTUIHarness(t, testStations)
+
+	h.key(tcell.KeyCtrlL)
+	h.waitForText("Nothing to like")
+
+	h.rune('~')
+	h.waitForPage(Main)
+	h.rune('c')
+	h.waitForText("Rock Radio | " + buffering)
+	h.backend.events <- Event{Kind: EventMetadata, Metadata: map[string]any{"icy-title": "Artist - Title"}}
+	h.waitForText("Rock Radio | Artist - Title")
+
+	h.key(tcell.KeyCtrlL)
+	h.waitForText("Liked | Artist - Title")
+
+	h.key(tcell.KeyCtrlL)
+	h.waitForText("Already liked | Artist - Title")
+
+	var sb strings.Builder
+	if err := NewLikedSongs().Export(&sb, "csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Artist,Title,Rock Radio," + testStations[2].url
+	if lines := strings.Split(strings.TrimSpace(sb.String()), "\n"); len(lines) != 2 || !strings.HasSuffix(lines[1], want) {
+		t.Errorf("exported:\n%s\nwant one song ending with %s", sb.String(), want)
+	}
+}
+
+func TestTUIDetails(t *testing.T) {
+	stations := slices.Clone(testStations)
+	stations[1].country = "NL"
+	stations[1].urls = []string{stations[1].url, "http://jazz.two/mirror"}
+
+	h := newTUIHarness(t, stations)
+
+	h.key(tcell.KeyCtrlD)
+	h.waitForText("Select or play a station")
+
+	h.rune('~')
+	h.waitForPage(Main)
+	// skip the tag and Random items
+	for range 3 {
+		h.key(tcell.KeyDown)
+	}
+
+	h.key(tcell.KeyCtrlD)
+	h.waitForPage(Details)
+	h.waitForText("Jazz Two")
+	h.waitForText("http://jazz.two/mirror")
+	h.waitForText("NL")
+	h.waitForNoText("Buffer")
+
+	h.key(tcell.KeyEscape)
+	h.waitForPage(Main)
+
+	h.key(tcell.KeyEnter)
+	h.waitForText("Jazz Two | " + buffering)
+	h.key(tcell.KeyCtrlD)
+	h.waitForPage(Details)
+	h.waitForText("Status       " + buffering)
+}
+
+func TestTUIReloadStations(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "stations.csv")
+	os.WriteFile(file, []byte("Jazz One,http://jazz.one/stream,Jazz\nJazz Two,http://jazz.two/stream,Jazz\n"), 0644)
+
+	stations, _ := Stations(file)
+	h := newTUIHarness(t, stations)
+	go h.app.watchStations([]string{file}, []string{file}, 20*time.Millisecond)
+
+	h.rune('a')
+	h.waitForPage(Main)
+	h.key(tcell.KeyDown)
+	h.key(tcell.KeyDown)
+	h.key(tcell.KeyDown)
+	h.key(tcell.KeyEnter)
+	h.waitForText("Jazz Two | " + buffering)
+
+	os.WriteFile(file, []byte("Jazz Zero,http://jazz.zero/stream,Jazz\n"+
+		"Jazz One,http://jazz.one/stream,Jazz\n"+
+		"Jazz Two (renamed),http://jazz.two/stream,Jazz\n"+
+		"Rock Radio,http://rock.radio/stream,Rock\n"), 0644)
+
+	h.waitForText("Reloaded 4 stations")
+	h.waitForText("Jazz Zero")
+	h.waitForText("Jazz Two (renamed)")
+	h.waitForNoText("Rock Radio")
+
+	h.eventually(func() bool {
+		ch := make(chan string, 1)
+		h.app.app.QueueUpdate(func() {
+			text, _ := h.app.stationsList.GetItemText(h.app.stationsList.GetCurrentItem())
+			ch <- text
+		})
+		return <-ch == "Jazz Two (renamed)"
+	}, func() string {
+		return fmt.Sprintf("the selected station was lost:\n%s", h.text())
+	})
+
+	if h.backend.lastLoaded() != "http://jazz.two/stream" {
+		t.Errorf("playback changed to %q", h.backend.lastLoaded())
+	}
+
+	h.key(tcell.KeyEscape)
+	h.waitForPage(Tags)
+	h.waitForText("Rock")
+}
+
+func TestTUIEditStations(t *testing.T) {
+	h := newTUIHarness(t, testStations)
+	file := userStationsFile()
+
+	h.key(tcell.KeyCtrlN)
+	h.waitForText("Add Station")
+	h.typeText("New Radio")
+	h.key(tcell.KeyTab)
+	h.typeText("http://new.radio/stream")
+	h.key(tcell.KeyTab)
+	h.typeText("Jazz;New")
+	h.key(tcell.KeyTab)
+	h.key(tcell.KeyEnter)
+	h.waitForText("Saved New Radio")
+
+	want := "title,url,tags\n" +
+		"Jazz One,http://jazz.one/stream,Jazz\n" +
+		"Jazz Two,http://jazz.two/stream,Jazz;Lounge\n" +
+		"Rock Radio,http://rock.radio/stream,Rock\n" +
+		"New Radio,http://new.radio/stream,Jazz;New\n"
+	if data, _ := os.ReadFile(file); string(data) != want {
+		t.Fatalf("got\n%s\nwant\n%s", data, want)
+	}
+
+	h.rune('a')
+	h.waitForPage(Main)
+	h.key(tcell.KeyDown)
+	h.key(tcell.KeyDown)
+	h.key(tcell.KeyDown)
+	h.key(tcell.KeyCtrlE)
+	h.waitForText("Edit Station")
+	h.key(tcell.KeyCtrlU)
+	h.typeText("Jazz 2")
+	h.key(tcell.KeyTab)
+	h.key(tcell.KeyTab)
+	h.key(tcell.KeyTab)
+	h.key(tcell.KeyEnter)
+	h.waitForText("Saved Jazz 2")
+
+	h.key(tcell.KeyDelete)
+	h.waitForText("Delete Jazz 2?")
+	h.key(tcell.KeyLeft)
+	h.key(tcell.KeyEnter)
+	h.waitForText("Deleted Jazz 2")
+
+	want = "title,url,tags\n" +
+		"Jazz One,http://jazz.one/stream,Jazz\n" +
+		"Rock Radio,http://rock.radio/stream,Rock\n" +
+		"New Radio,http://new.radio/stream,Jazz;New\n"
+	if data, _ := os.ReadFile(file); string(data) != want {
+		t.Errorf("got\n%s\nwant\n%s", data, want)
+	}
+
+	if stations, _ := Stations(); len(stations) != 3 {
+		t.Errorf("got %d stations from the user stations file, want 3", len(stations))
+	}
+}
+
+func TestTUIEditFavorite(t *testing.T) {
+	h := newTUIHarness(t, testStations)
+
+	h.app.app.QueueUpdateDraw(func() {
+		h.app.favorites.track(testStations[0])
+		h.app.refreshTagsPage()
+		h.app.app.SetFocus(h.app.tagsList)
+	})
+	h.waitForText("Favorites")
+	h.rune('$')
+	h.waitForText("Jazz One (1)")
+
+	h.key(tcell.KeyDown)
+	h.key(tcell.KeyDown)
+	h.key(tcell.KeyCtrlE)
+	h.waitForText("Edit Station")
+
+	var title, tags string
+	h.app.app.QueueUpdate(func() {
+		title = h.app.editor.GetFormItem(0).(*tview.InputField).GetText()
+		tags = h.app.editor.GetFormItem(2).(*tview.InputField).GetText()
+	})
+	if title != "Jazz One" || tags != "Jazz" {
+		t.Errorf("got title %q and tags %q, want the station as it is in the stations", title, tags)
+	}
+}
+
+func TestTUISaveBrowseResult(t *testing.T) {
+	h := newTUIHarness(t, testStations)
+	found := []Station{
+		testStations[0],
+		{title: "Found FM", url: "http://found.fm/stream", tags: []string{"jazz", "found"}, country: "LT", bitrate: 128},
+	}
+
+	h.key(tcell.KeyCtrlS)
+	h.waitForText("Countries")
+	h.waitForText("Languages")
+	h.app.app.QueueUpdateDraw(func() {
+		h.app.browseResults.Clear()
+		h.app.browseFound = found
+		for i, s := range found {
+			h.app.browseResults.AddItem(s.title, "", idxToRune(i), nil)
+		}
+	})
+	h.key(tcell.KeyDown)
+	h.key(tcell.KeyCtrlA)
+	h.waitForText("Jazz One is already saved")
+
+	h.key(tcell.KeyDown)
+	h.key(tcell.KeyCtrlA)
+	h.waitForText("Found FM (saved)")
+
+	stations, _ := Stations()
+	i := slices.IndexFunc(stations, func(s Station) bool { return s.url == "http://found.fm/stream" })
+	if len(stations) != 4 || i < 0 {
+		t.Fatalf("the station wasn't saved: %v", stations)
+	}
+	if s := stations[i]; s.country != "LT" || s.bitrate != 128 || !slices.Equal(s.tags, []string{"jazz", "found"}) {
+		t.Errorf("got %+v", s)
+	}
+
+	// a file without a header gets one to keep the radio-browser details
+	file := filepath.Join(t.TempDir(), "stations.csv")
+	os.WriteFile(file, []byte("Mine,http://mine/,Rock,note\n"), 0644)
+
+	other := Station{title: "Other FM", url: "http://other.fm/", tags: []string{"pop"}, country: "LV", bitrate: 64, uuid: "abc"}
+	h.app.app.QueueUpdateDraw(func() {
+		h.app.sources = []string{file}
+		h.app.browseFound = append(found, other)
+		h.app.browseResults.AddItem(other.title, "", idxToRune(2), nil)
+	})
+	h.key(tcell.KeyDown)
+	h.key(tcell.KeyCtrlA)
+	h.waitForText("Other FM (saved)")
+
+	want := "title,url,tags,extra1,country,bitrate,uuid\nMine,http://mine/,Rock,note\nOther FM,http://other.fm/,pop,,LV,64,abc\n"
+	if data, _ := os.ReadFile(file); string(data) != want {
+		t.Errorf("got\n%s\nwant\n%s", data, want)
+	}
+}
@@ -0,0 +1,78 @@
+package radio
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	EventPlaying EventKind = iota
+	EventEndFile
+	EventMetadata
+	EventBitrate
+	EventRestarted
+	EventCodec
+)
+
+var Backends = []string{"mpv", "ffplay"}
+
+// Backend is an audio player driven by Player. Implementations report
+// what happens to the loaded stream through the Events channel. Record
+// copies the loaded stream into a file at path, an empty path stops it.
+type Backend interface {
+	Start() error
+	Load(url string) error
+	Stop() error
+	SetVolume(volume int) error
+	Record(path string) error
+	Quit() error
+	Events() <-chan Event
+}
+
+// streamInspector is implemented by the backends that can tell more about
+// the stream they play.
+type streamInspector interface {
+	StreamInfo() (StreamInfo, error)
+}
+
+// StreamInfo describes the stream that is playing, zero values are unknown.
+type StreamInfo struct {
+	URL        string
+	Format     string
+	Codec      string
+	SampleRate int
+	Channels   int
+	Layout     string
+	Bitrate    int
+	ICY        map[string]string
+	Buffered   time.Duration
+	BufferSize int64
+	Buffering  bool
+}
+
+type EventKind int
+
+type Event struct {
+	Kind     EventKind
+	Reason   string
+	Error    string
+	Metadata map[string]any
+	Bitrate  int
+	Codec    string
+}
+
+func NewBackend(name string) (Backend, error) {
+	switch name {
+	case "", "mpv":
+		return newMPVBackend(socket), nil
+	case "ffplay":
+		return newFFplayBackend(), nil
+	}
+
+	return nil, fmt.Errorf("unknown backend %q, expected one of %v", name, Backends)
+}
+
+func isUnexpectedEnd(e Event) bool {
+	return e.Kind == EventEndFile && slices.Contains([]string{"eof", "error", "unknown"}, e.Reason)
+}
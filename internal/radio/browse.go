@@ -2,6 +2,9 @@ package radio
 
 import (
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
@@ -22,11 +25,16 @@ func (a *Application) setupBrowseModal() {
 			a.pages.HidePage(a.pageNames[Browse])
 			return nil
 		case tcell.KeyEnter:
-			query := a.browseInput.GetText()
-			if query != "" {
-				go a.doBrowseSearch(query)
+			q := a.browseQuery
+			q.Name = a.browseInput.GetText()
+			if q.String() != "" {
+				a.browseBack = a.showBrowseDirectories
+				go a.doBrowseSearch(q)
 			}
 			return nil
+		case tcell.KeyCtrlO:
+			a.showBrowseFilters()
+			return nil
 		case tcell.KeyDown, tcell.KeyTab:
 			a.app.SetFocus(a.browseResults)
 			if a.browseResults.GetItemCount() > 0 {
@@ -48,15 +56,31 @@ func (a *Application) setupBrowseModal() {
 				a.app.SetFocus(a.browseInput)
 				return nil
 			}
+		case tcell.KeyCtrlA:
+			a.saveBrowseResult(a.browseResults.GetCurrentItem())
+			return nil
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			if a.browseBack != nil {
+				a.browseBack()
+			}
+			return nil
+		case tcell.KeyCtrlO:
+			a.showBrowseFilters()
+			return nil
 		}
 		return event
 	})
 
+	a.browseFiltersText = tview.NewTextView().SetDynamicColors(true)
+	a.browseFiltersText.SetBackgroundColor(tcell.ColorDefault)
+	a.updateBrowseFiltersText()
+
 	browseContent := tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(a.browseInput, 1, 0, true).
+		AddItem(a.browseFiltersText, 1, 0, false).
 		AddItem(a.browseResults, 0, 1, false)
 
-	browseContent.SetBorder(true).SetTitle(" Radio Browser ").SetBackgroundColor(tcell.ColorDefault)
+	browseContent.SetBorder(true).SetTitle(" Radio Browser (Ctrl+A saves a station) ").SetBackgroundColor(tcell.ColorDefault)
 
 	a.browseModal = tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(nil, 0, 5, false).
@@ -67,52 +91,205 @@ func (a *Application) setupBrowseModal() {
 		AddItem(nil, 0, 5, false)
 
 	a.pages.AddPage(a.pageNames[Browse], a.browseModal, true, false)
+
+	a.setupBrowseFilters()
+}
+
+func (a *Application) setupBrowseFilters() {
+	a.browseFilters = tview.NewForm().
+		SetFieldBackgroundColor(tcell.ColorBlack).
+		SetLabelColor(tcell.ColorYellow).
+		SetButtonBackgroundColor(tcell.ColorBlack).
+		SetCancelFunc(a.closeBrowseFilters)
+	a.browseFilters.SetBorder(true).SetTitle(" Search Filters ").SetBackgroundColor(tcell.ColorDefault)
+
+	filtersModal := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+			AddItem(nil, 0, 15, false).
+			AddItem(a.browseFilters, 0, 70, true).
+			AddItem(nil, 0, 15, false), 17, 0, true).
+		AddItem(nil, 0, 1, false)
+
+	a.pages.AddPage(a.pageNames[Filters], filtersModal, true, false)
+}
+
+func (a *Application) showBrowseFilters() {
+	q := a.browseQuery
+	bitrate := ""
+	if q.MinBitrate > 0 {
+		bitrate = strconv.Itoa(q.MinBitrate)
+	}
+
+	a.browseFilters.Clear(true).
+		AddInputField("Tag", q.Tag, 0, nil, nil).
+		AddInputField("Country", q.Country, 0, nil, nil).
+		AddInputField("Language", q.Language, 0, nil, nil).
+		AddInputField("Codec", q.Codec, 0, nil, nil).
+		AddInputField("Min kb/s", bitrate, 0, tview.InputFieldInteger, nil).
+		AddDropDown("Order", RadioBrowserOrders, max(slices.Index(RadioBrowserOrders, q.Order), 0), nil).
+		AddButton("Search", a.applyBrowseFilters).
+		AddButton("Clear", func() {
+			for i := range 5 {
+				a.browseFilters.GetFormItem(i).(*tview.InputField).SetText("")
+			}
+			a.browseFilters.GetFormItem(5).(*tview.DropDown).SetCurrentOption(0)
+		}).
+		AddButton("Cancel", a.closeBrowseFilters)
+
+	a.browseFilters.SetFocus(0)
+	a.pages.ShowPage(a.pageNames[Filters])
+	a.app.SetFocus(a.browseFilters)
+}
+
+func (a *Application) closeBrowseFilters() {
+	a.pages.HidePage(a.pageNames[Filters])
+	a.app.SetFocus(a.browseInput)
+}
+
+// applyBrowseFilters keeps the filters for the next searches and searches
+// with them right away.
+func (a *Application) applyBrowseFilters() {
+	field := func(i int) string {
+		return strings.TrimSpace(a.browseFilters.GetFormItem(i).(*tview.InputField).GetText())
+	}
+
+	a.browseQuery.Tag = field(0)
+	a.browseQuery.Country = field(1)
+	a.browseQuery.Language = field(2)
+	a.browseQuery.Codec = field(3)
+	a.browseQuery.MinBitrate, _ = strconv.Atoi(field(4))
+	_, a.browseQuery.Order = a.browseFilters.GetFormItem(5).(*tview.DropDown).GetCurrentOption()
+
+	a.updateBrowseFiltersText()
+	a.closeBrowseFilters()
+
+	q := a.browseQuery
+	q.Name = a.browseInput.GetText()
+	if q.String() != "" {
+		go a.doBrowseSearch(q)
+	}
+}
+
+func (a *Application) updateBrowseFiltersText() {
+	filters := a.browseQuery.filters()
+	if filters == "" {
+		filters = "none"
+	}
+
+	order := a.browseQuery.Order
+	if order == "" {
+		order = RadioBrowserOrders[0]
+	}
+
+	a.browseFiltersText.SetText(fmt.Sprintf("[gray]Filters: %s, by %s (Ctrl+O to change)", stripBraces(filters), order))
 }
 
 func (a *Application) showBrowseModal() {
 	a.browseInput.SetText("")
-	a.browseResults.Clear()
+	a.showBrowseDirectories()
 	a.pages.ShowPage(a.pageNames[Browse])
 	a.app.SetFocus(a.browseInput)
 }
 
-func (a *Application) doBrowseSearch(query string) {
+// showBrowseDirectories lists the ranked lists and the directories of
+// radio-browser until a search is made.
+func (a *Application) showBrowseDirectories() {
+	a.browseResults.Clear()
+	a.browseFound = nil
+	a.browseBack = nil
+
+	for _, l := range radioBrowserLists {
+		q := RadioBrowserQuery{list: l.list, title: l.title}
+		a.browseResults.AddItem(l.title, "", idxToRune(a.browseResults.GetItemCount()), func() {
+			a.browseBack = a.showBrowseDirectories
+			go a.doBrowseSearch(q)
+		})
+	}
+
+	for _, dir := range radioBrowserDirectories {
+		a.browseResults.AddItem(dir.title, "", idxToRune(a.browseResults.GetItemCount()), func() {
+			go a.doBrowseCategories(dir)
+		})
+	}
+}
+
+// doBrowseCategories lists the categories of the directory, selecting one
+// shows its stations.
+func (a *Application) doBrowseCategories(dir radioBrowserDirectory) {
 	a.app.QueueUpdateDraw(func() {
 		a.browseResults.Clear()
-		a.browseResults.AddItem("[yellow]Searching...[-]", "", 0, nil)
+		a.browseResults.AddItem("[yellow]Loading...[-]", "", 0, nil)
 	})
 
-	results, err := SearchRadioBrowser(query)
+	categories, err := radioBrowserCategories(dir)
 
 	a.app.QueueUpdateDraw(func() {
 		a.browseResults.Clear()
+		a.browseFound = nil
+		a.browseBack = a.showBrowseDirectories
 
 		if err != nil {
 			a.browseResults.AddItem(fmt.Sprintf("[red]Error: %s[-]", err.Error()), "", 0, nil)
 			return
 		}
 
-		if len(results) == 0 {
-			a.browseResults.AddItem("No stations found", "", rune('!'), nil)
+		for i, c := range categories {
+			title := fmt.Sprintf("%s [gray](%d)[-]", stripBraces(c.Name), c.StationCount)
+			a.browseResults.AddItem(title, "", idxToRune(i), func() {
+				q := dir.query(c)
+				q.Order = a.browseQuery.Order
+				a.browseBack = func() {
+					go a.doBrowseCategories(dir)
+				}
+				go a.doBrowseSearch(q)
+			})
+		}
+	})
+}
+
+// doBrowseSearch shows the stations found by the query, a query with an
+// offset adds the next page of them.
+func (a *Application) doBrowseSearch(q RadioBrowserQuery) {
+	a.app.QueueUpdateDraw(func() {
+		if q.Offset == 0 {
+			a.browseResults.Clear()
+			a.browseResults.AddItem("[yellow]Searching...[-]", "", 0, nil)
+		} else {
+			a.browseResults.SetItemText(a.browseResults.GetItemCount()-1, "[yellow]Searching...[-]", "")
+		}
+	})
+
+	results, more, err := SearchRadioBrowser(q)
+
+	a.app.QueueUpdateDraw(func() {
+		// drop the "Searching..." item
+		a.browseResults.RemoveItem(a.browseResults.GetItemCount() - 1)
+		if q.Offset == 0 {
+			a.browseFound = nil
+		}
+
+		if err != nil {
+			a.browseResults.AddItem(fmt.Sprintf("[red]Error: %s[-]", err.Error()), "", 0, nil)
 			return
 		}
 
-		allStations := make([]Station, len(results))
-		for i, r := range results {
-			allStations[i] = r.station
+		if len(results) == 0 && q.Offset == 0 {
+			a.browseResults.AddItem("No stations found", "", rune('!'), nil)
+			return
 		}
 
-		for i, r := range results {
+		for _, r := range results {
+			i := len(a.browseFound)
+			a.browseFound = append(a.browseFound, r.station)
+
 			displayTitle := stripBraces(r.station.title)
-			meta := ""
-			if r.countryCode != "" {
-				meta = r.countryCode
-			}
-			if r.bitrate > 0 {
+			meta := r.station.country
+			if r.station.bitrate > 0 {
 				if meta != "" {
 					meta += ", "
 				}
-				meta += fmt.Sprintf("%dk", r.bitrate)
+				meta += fmt.Sprintf("%dk", r.station.bitrate)
 			}
 			if meta != "" {
 				displayTitle += fmt.Sprintf(" [gray](%s)[-]", meta)
@@ -120,7 +297,15 @@ func (a *Application) doBrowseSearch(query string) {
 
 			station := r.station
 			a.browseResults.AddItem(displayTitle, "", idxToRune(i), func() {
-				a.selectBrowseResult(query, station, allStations)
+				a.selectBrowseResult(q.String(), station, slices.Clone(a.browseFound))
+			})
+		}
+
+		if more {
+			next := q
+			next.Offset += radioBrowserPageSize
+			a.browseResults.AddItem("[yellow]More results...[-]", "", 0, func() {
+				go a.doBrowseSearch(next)
 			})
 		}
 	})
@@ -139,3 +324,61 @@ func (a *Application) selectBrowseResult(query string, selectedStation Station,
 	a.stationsList.SetCurrentItem(stationIndex)
 	go a.togglePlayManual(selectedStation)
 }
+
+// voteStation votes for the station selected in the radio-browser results or
+// on the main page, or the one that is playing.
+func (a *Application) voteStation() {
+	station, ok := Station{}, false
+	if front, _ := a.pages.GetFrontPage(); front == a.pageNames[Browse] {
+		if i := a.browseResults.GetCurrentItem(); i >= 0 && i < len(a.browseFound) {
+			station, ok = a.browseFound[i], true
+		}
+	} else {
+		station, ok = a.detailsStation()
+	}
+
+	if !ok || station.uuid == "" {
+		a.status.SetText("[red]Only radio-browser.info stations can be voted for")
+		return
+	}
+
+	title := stripBraces(stripPlayCount(station.title))
+
+	go func() {
+		err := radioBrowserVote(station.uuid)
+
+		a.app.QueueUpdateDraw(func() {
+			if err != nil {
+				log.Println(err)
+				a.status.SetText(fmt.Sprintf("[red]Can't vote for %s: %s", title, stripBraces(err.Error())))
+				return
+			}
+			a.status.SetText(fmt.Sprintf("[yellow]Voted for %s", title))
+		})
+	}()
+}
+
+// saveBrowseResult adds the i-th found station to the stations file, so that
+// it is listed under its tags from then on.
+func (a *Application) saveBrowseResult(i int) {
+	if i < 0 || i >= len(a.browseFound) {
+		return
+	}
+
+	station := a.browseFound[i]
+	title, _ := a.browseResults.GetItemText(i)
+
+	if slices.ContainsFunc(a.stations, func(s Station) bool { return s.url == station.url }) {
+		a.status.SetText(fmt.Sprintf("[yellow]%s is already saved", stripBraces(station.title)))
+		return
+	}
+
+	if err := a.saveStation("", &station); err != nil {
+		log.Println(err)
+		a.status.SetText(fmt.Sprintf("[red]Can't save %s: %s", stripBraces(station.title), stripBraces(err.Error())))
+		return
+	}
+
+	a.browseResults.SetItemText(i, title+" [green](saved)[-]", "")
+	a.status.SetText(fmt.Sprintf("[yellow]Saved %s", stripBraces(station.title)))
+}
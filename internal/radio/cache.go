@@ -0,0 +1,84 @@
+package radio
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var stationsClient = &http.Client{Timeout: 15 * time.Second}
+
+// stationsCache describes the cached copy of a remote stations list.
+type stationsCache struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	ContentType  string    `json:"content_type,omitempty"`
+	Fetched      time.Time `json:"fetched"`
+	data         []byte
+}
+
+// stationsCacheFiles returns the files keeping the description and the data
+// of a cached list.
+func stationsCacheFiles(url string) (string, string) {
+	sum := sha256.Sum256([]byte(url))
+	name := hex.EncodeToString(sum[:8])
+	dir := getConfigFile("cache")
+
+	return filepath.Join(dir, name+".json"), filepath.Join(dir, name+".data")
+}
+
+func readStationsCache(url string) (stationsCache, bool) {
+	var cache stationsCache
+	metaFile, dataFile := stationsCacheFiles(url)
+
+	meta, err := os.ReadFile(metaFile)
+	if err != nil {
+		return cache, false
+	}
+
+	if err := json.Unmarshal(meta, &cache); err != nil || cache.URL != url {
+		log.Printf("Ignoring the cache of %s: %v", url, err)
+		return cache, false
+	}
+
+	if cache.data, err = os.ReadFile(dataFile); err != nil {
+		log.Println(err)
+		return cache, false
+	}
+
+	return cache, true
+}
+
+func writeStationsCache(url string, resp *http.Response, data []byte) {
+	metaFile, dataFile := stationsCacheFiles(url)
+
+	cache := stationsCache{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  resp.Header.Get("Content-Type"),
+		Fetched:      time.Now(),
+	}
+
+	meta, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	os.MkdirAll(filepath.Dir(metaFile), 0755)
+
+	if err := os.WriteFile(dataFile, data, 0644); err != nil {
+		log.Printf("Failed to cache %s: %v", url, err)
+		return
+	}
+
+	if err := os.WriteFile(metaFile, meta, 0644); err != nil {
+		log.Printf("Failed to cache %s: %v", url, err)
+	}
+}
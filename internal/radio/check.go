@@ -0,0 +1,220 @@
+package radio
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CheckFormats are the report formats of CheckStations.
+var CheckFormats = []string{"text", "json", "csv"}
+
+// CheckOptions tell CheckStations how many stations to check at once, how
+// long a station may take and how to report the results.
+type CheckOptions struct {
+	Workers int
+	Timeout time.Duration
+	Format  string
+}
+
+// checkResult is the report of a station, a live station has the details
+// of the stream that responded unless only mpv could play it.
+type checkResult struct {
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Alive       bool   `json:"alive"`
+	Stream      string `json:"stream,omitempty"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	LatencyMS   int64  `json:"latency_ms,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// CheckStations checks whether the streams of the stations respond, writes
+// the report to w and returns the number of dead stations.
+func CheckStations(w io.Writer, stations []Station, opts CheckOptions) (int, error) {
+	if opts.Format == "" {
+		opts.Format = "text"
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+
+	switch opts.Format {
+	case "text", "json", "csv":
+	default:
+		return 0, fmt.Errorf("unknown check format %q, use one of %v", opts.Format, CheckFormats)
+	}
+
+	results := make([]checkResult, len(stations))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for range min(opts.Workers, len(stations)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = checkStation(stations[i], opts.Timeout)
+			}
+		}()
+	}
+
+	for i := range stations {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	dead := 0
+	for _, r := range results {
+		if !r.Alive {
+			dead++
+		}
+	}
+
+	return dead, writeCheckResults(w, results, opts.Format)
+}
+
+// checkStation gives up on the station after the timeout, a station is alive
+// when one of its streams is.
+func checkStation(s Station, timeout time.Duration) checkResult {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan checkResult, 1)
+	go func() {
+		done <- checkStreams(ctx, s, &http.Client{Timeout: timeout})
+	}()
+
+	select {
+	case r := <-done:
+		return r
+	case <-ctx.Done():
+		return checkResult{Title: s.title, URL: s.url, Reason: fmt.Sprintf("no response in %s", timeout)}
+	}
+}
+
+func checkStreams(ctx context.Context, s Station, client *http.Client) checkResult {
+	r := checkResult{Title: s.title, URL: s.url}
+
+	for _, u := range s.streams() {
+		p, err := probeStream(ctx, client, u)
+		if err == nil && playlistKind(p.ContentType, u) != "" {
+			p, err = probePlaylist(ctx, client, u)
+		}
+
+		p.Title, p.URL = r.Title, r.URL
+		if err == nil {
+			return p
+		}
+
+		if checkMpv(ctx, u) {
+			return checkResult{Title: r.Title, URL: r.URL, Alive: true, Stream: u}
+		}
+
+		p.Reason = err.Error()
+		r = p
+	}
+
+	return r
+}
+
+// probePlaylist reports the first stream of the playlist that responds.
+func probePlaylist(ctx context.Context, client *http.Client, rawURL string) (checkResult, error) {
+	streams, err := resolvePlaylist(client, rawURL, 0)
+	if err != nil {
+		return checkResult{}, err
+	}
+
+	var r checkResult
+	for _, stream := range streams {
+		if r, err = probeStream(ctx, client, stream); err == nil {
+			return r, nil
+		}
+	}
+
+	return r, err
+}
+
+// probeStream tells how the stream responds, without reading it.
+func probeStream(ctx context.Context, client *http.Client, rawURL string) (checkResult, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return checkResult{}, err
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return checkResult{}, err
+	}
+	resp.Body.Close()
+
+	r := checkResult{
+		Stream:      rawURL,
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		LatencyMS:   time.Since(start).Milliseconds(),
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return r, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+
+	r.Alive = true
+	return r, nil
+}
+
+func checkMpv(ctx context.Context, rawURL string) bool {
+	cmd := exec.CommandContext(ctx, "mpv", "--no-video", "--no-terminal", "--frames=1", rawURL)
+	return cmd.Run() == nil
+}
+
+func writeCheckResults(w io.Writer, results []checkResult, format string) error {
+	switch format {
+	case "json":
+		if results == nil {
+			results = []checkResult{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"title", "url", "alive", "stream", "status_code", "content_type", "latency_ms", "reason"})
+		for _, r := range results {
+			status := ""
+			if r.StatusCode > 0 {
+				status = strconv.Itoa(r.StatusCode)
+			}
+			latency := ""
+			if r.LatencyMS > 0 {
+				latency = strconv.FormatInt(r.LatencyMS, 10)
+			}
+			cw.Write([]string{r.Title, r.URL, strconv.FormatBool(r.Alive), r.Stream, status, r.ContentType, latency, r.Reason})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	dead := 0
+	for _, r := range results {
+		if !r.Alive {
+			fmt.Fprintf(w, "  DEAD  %s  (%s)\n", r.Title, r.Reason)
+			dead++
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d/%d stations alive, %d dead\n", len(results)-dead, len(results), dead)
+	return err
+}
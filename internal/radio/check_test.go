@@ -0,0 +1,96 @@
+package radio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckStations(t *testing.T) {
+	t.Setenv("PATH", "") // no mpv to fall back to
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/live", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+	})
+	mux.HandleFunc("/radio.pls", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "[playlist]\nFile1=%s/gone\nFile2=%s/live\n", srv.URL, srv.URL)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	stations := []Station{
+		{title: "Live", url: srv.URL + "/live"},
+		{title: "Gone", url: srv.URL + "/gone"},
+		{title: "Playlist", url: srv.URL + "/radio.pls"},
+		{title: "Slow", url: srv.URL + "/slow"},
+		{title: "Mirror", url: srv.URL + "/gone", urls: []string{srv.URL + "/gone", srv.URL + "/live"}},
+	}
+
+	var out bytes.Buffer
+	dead, err := CheckStations(&out, stations, CheckOptions{Workers: 2, Timeout: 200 * time.Millisecond, Format: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dead != 2 {
+		t.Errorf("got %d dead stations, want 2", dead)
+	}
+
+	var results []checkResult
+	if err := json.Unmarshal(out.Bytes(), &results); err != nil {
+		t.Fatalf("%v: %s", err, out.String())
+	}
+
+	want := []struct {
+		alive       bool
+		stream      string
+		status      int
+		contentType string
+		reason      string
+	}{
+		{true, "/live", 200, "audio/mpeg", ""},
+		{false, "/gone", 404, "text/plain; charset=utf-8", "404 Not Found"},
+		{true, "/live", 200, "audio/mpeg", ""},
+		{false, "", 0, "", "no response in 200ms"},
+		{true, "/live", 200, "audio/mpeg", ""},
+	}
+
+	for i, w := range want {
+		r := results[i]
+		if r.Title != stations[i].title || r.Alive != w.alive || r.StatusCode != w.status || r.ContentType != w.contentType ||
+			strings.TrimPrefix(r.Stream, srv.URL) != w.stream || !strings.Contains(r.Reason, w.reason) || (w.reason == "" && r.Reason != "") {
+			t.Errorf("%s: got %+v", stations[i].title, r)
+		}
+	}
+
+	out.Reset()
+	CheckStations(&out, stations[:2], CheckOptions{Format: "csv"})
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 3 ||
+		lines[0] != "title,url,alive,stream,status_code,content_type,latency_ms,reason" ||
+		!strings.HasPrefix(lines[1], "Live,"+srv.URL+"/live,true,"+srv.URL+"/live,200,audio/mpeg,") {
+		t.Errorf("got %s", out.String())
+	}
+
+	out.Reset()
+	CheckStations(&out, stations[:2], CheckOptions{})
+	if got := out.String(); !strings.Contains(got, "  DEAD  Gone  (GET "+srv.URL+"/gone: 404 Not Found)") ||
+		!strings.Contains(got, "1/2 stations alive, 1 dead") {
+		t.Errorf("got %s", got)
+	}
+
+	if _, err := CheckStations(&out, stations, CheckOptions{Format: "xml"}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
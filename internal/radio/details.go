@@ -0,0 +1,197 @@
+package radio
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+func (a *Application) setupDetailsPage() {
+	a.details = tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(true)
+	a.details.SetBackgroundColor(tcell.ColorDefault)
+
+	detailsFlex := tview.NewFlex().
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(a.details, 0, 100, true).
+			AddItem(a.newStatusFlex(), 0, 1, false), 0, 1, true)
+
+	a.pages.AddPage(a.pageNames[Details], detailsFlex, true, false)
+}
+
+func (a *Application) showDetails() {
+	station, ok := a.detailsStation()
+	if !ok {
+		a.status.SetText("[red]Select or play a station to see its details")
+		return
+	}
+
+	a.details.SetText(stationDetails(station)).ScrollToBeginning()
+	a.show(Details)
+
+	a.detailsShown++
+	if station.url == a.lastInfo.Url {
+		go a.updateStreamDetails(station, a.detailsShown)
+	}
+}
+
+// updateStreamDetails adds what the backend tells about the stream to the
+// details of the playing station, refreshing it while the details are shown.
+func (a *Application) updateStreamDetails(station Station, shown int) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		info, ok := a.player.StreamInfo()
+		visible := false
+
+		a.app.QueueUpdateDraw(func() {
+			front, _ := a.pages.GetFrontPage()
+			visible = front == a.pageNames[Details] && a.detailsShown == shown && a.lastInfo.Url == station.url
+			if !visible {
+				return
+			}
+
+			row, col := a.details.GetScrollOffset()
+			a.details.SetText(stationDetails(station)+streamDetails(a.lastInfo, info, ok)).ScrollTo(row, col)
+		})
+
+		if !visible {
+			return
+		}
+		<-ticker.C
+	}
+}
+
+// detailsStation returns the station selected on the main page, or the one
+// that is playing.
+func (a *Application) detailsStation() (Station, bool) {
+	if s, ok := a.selectedStation(); ok {
+		return s, true
+	}
+
+	if a.lastInfo.Url == "" {
+		return Station{}, false
+	}
+
+	for _, stations := range [][]Station{a.listedStations, a.stations} {
+		for _, s := range stations {
+			if s.url == a.lastInfo.Url {
+				return s, true
+			}
+		}
+	}
+
+	return Station{title: a.lastInfo.Station, url: a.lastInfo.Url}, true
+}
+
+func stationDetails(s Station) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "[green]%s[-]\n\n", stripBraces(stripPlayCount(s.title)))
+
+	row := func(name, value string) {
+		detailsRow(&sb, name, value)
+	}
+
+	for i, url := range s.streams() {
+		if i == 0 {
+			row("URL", url)
+		} else {
+			row("Mirror", url)
+		}
+	}
+
+	row("Tags", strings.Join(s.tags, ", "))
+	row("Homepage", s.homepage)
+	row("Country", s.country)
+	row("Language", s.language)
+	row("Codec", s.codec)
+	if s.bitrate > 0 {
+		row("Bitrate", fmt.Sprintf("%d kb/s", s.bitrate))
+	}
+	if s.volumeOffset != 0 {
+		row("Volume", fmt.Sprintf("%+d%%", s.volumeOffset))
+	}
+	row("Logo", s.logo)
+	row("Description", s.description)
+
+	return sb.String()
+}
+
+// streamDetails describes the stream that is playing, with what the backend
+// tells about it when it can.
+func streamDetails(inf Info, s StreamInfo, ok bool) string {
+	var sb strings.Builder
+
+	row := func(name, value string) {
+		detailsRow(&sb, name, value)
+	}
+
+	sb.WriteString("\n[green]Stream[-]\n\n")
+
+	row("Status", inf.Status)
+	row("Stream", cmp.Or(s.URL, inf.Stream))
+	if inf.Mirrors > 1 {
+		row("Mirror", fmt.Sprintf("%d of %d", inf.Mirror, inf.Mirrors))
+	}
+	row("Format", s.Format)
+	row("Codec", cmp.Or(s.Codec, inf.Codec))
+	if s.SampleRate > 0 {
+		row("Sample rate", fmt.Sprintf("%d Hz", s.SampleRate))
+	}
+	if s.Channels > 0 {
+		channels := strconv.Itoa(s.Channels)
+		if s.Layout != "" {
+			channels += fmt.Sprintf(" (%s)", s.Layout)
+		}
+		row("Channels", channels)
+	}
+	if bitrate := cmp.Or(s.Bitrate, inf.Bitrate); bitrate > 0 {
+		row("Bitrate", fmt.Sprintf("%d kb/s", bitrate))
+	}
+
+	// the common ICY headers first, then the rest of them
+	icy := slices.Sorted(maps.Keys(s.ICY))
+	slices.SortStableFunc(icy, func(a, b string) int {
+		return cmp.Compare(icyOrder(a), icyOrder(b))
+	})
+	for _, name := range icy {
+		row(name, s.ICY[name])
+	}
+
+	if ok {
+		buffer := fmt.Sprintf("%.1f s", s.Buffered.Seconds())
+		if s.BufferSize > 0 {
+			buffer += fmt.Sprintf(", %d KiB", s.BufferSize/1024)
+		}
+		if s.Buffering {
+			buffer += " [yellow](waiting for data)[-]"
+		}
+		fmt.Fprintf(&sb, "  [gray]%-12s[-] %s\n", "Buffer", buffer)
+	}
+
+	return sb.String()
+}
+
+func icyOrder(name string) int {
+	if i := slices.Index([]string{"icy-name", "icy-genre", "icy-url", "icy-description", "icy-br"}, name); i >= 0 {
+		return i
+	}
+	return math.MaxInt
+}
+
+func detailsRow(sb *strings.Builder, name, value string) {
+	if value != "" {
+		fmt.Fprintf(sb, "  [gray]%-12s[-] %s\n", name, stripBraces(value))
+	}
+}
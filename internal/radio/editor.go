@@ -0,0 +1,410 @@
+package radio
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// stationColumns are added to the header of a CSV file in this order when
+// the file misses them, a new file starts with the first three.
+var stationColumns = []string{"title", "url", "tags", "homepage", "country", "language",
+	"codec", "bitrate", "logo", "description", "volume", "uuid"}
+
+var errNotInFile = errors.New("station is not in the file")
+
+func (a *Application) setupEditor() {
+	a.editor = tview.NewForm().
+		SetFieldBackgroundColor(tcell.ColorBlack).
+		SetLabelColor(tcell.ColorGreen).
+		SetButtonBackgroundColor(tcell.ColorBlack).
+		SetCancelFunc(a.closeEditor)
+	a.editor.SetBorder(true).SetBackgroundColor(tcell.ColorDefault)
+
+	editorModal := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+			AddItem(nil, 0, 5, false).
+			AddItem(a.editor, 0, 90, true).
+			AddItem(nil, 0, 5, false), 11, 0, true).
+		AddItem(nil, 0, 1, false)
+
+	a.confirm = tview.NewModal()
+
+	a.pages.AddPage(a.pageNames[Editor], editorModal, true, false)
+	a.pages.AddPage(a.pageNames[Confirm], a.confirm, true, false)
+}
+
+// showEditor opens the form to edit the station, or to add a new one when
+// the station is nil.
+func (a *Application) showEditor(station *Station) {
+	s := Station{}
+	title := " Add Station "
+
+	if station != nil {
+		edited, err := a.editedStation(*station)
+		if err != nil {
+			a.status.SetText(fmt.Sprintf("[red]Can't edit %s: %s", stripBraces(stripPlayCount(station.title)),
+				stripBraces(err.Error())))
+			return
+		}
+		s = edited
+		title = " Edit Station "
+	}
+
+	a.editor.SetTitle(title)
+	a.editor.Clear(true).
+		AddInputField("Title", s.title, 0, nil, nil).
+		AddInputField("URL", strings.Join(s.streams(), " | "), 0, nil, nil).
+		AddInputField("Tags", strings.Join(s.tags, ";"), 0, nil, nil).
+		AddButton("Save", func() {
+			a.saveEditor(station, s)
+		}).
+		AddButton("Cancel", a.closeEditor)
+
+	a.editor.SetFocus(0)
+	a.pages.ShowPage(a.pageNames[Editor])
+	a.app.SetFocus(a.editor)
+}
+
+func (a *Application) closeEditor() {
+	a.pages.HidePage(a.pageNames[Editor])
+	a.app.SetFocus(a.pages)
+}
+
+func (a *Application) saveEditor(original *Station, s Station) {
+	field := func(i int) string {
+		return strings.TrimSpace(a.editor.GetFormItem(i).(*tview.InputField).GetText())
+	}
+
+	s.title = field(0)
+	s.urls = nil
+	for _, u := range strings.Split(field(1), "|") {
+		if u = strings.TrimSpace(u); u != "" {
+			s.urls = append(s.urls, u)
+		}
+	}
+	s.tags = nil
+	for _, t := range strings.Split(field(2), ";") {
+		if t = strings.TrimSpace(t); t != "" {
+			s.tags = append(s.tags, t)
+		}
+	}
+
+	if s.title == "" || len(s.urls) == 0 {
+		a.editor.SetTitle(" [red]A station needs a title and a URL ")
+		return
+	}
+	s.url = s.urls[0]
+
+	url := ""
+	if original != nil {
+		url = original.url
+	}
+
+	if err := a.saveStation(url, &s); err != nil {
+		log.Println(err)
+		a.editor.SetTitle(fmt.Sprintf(" [red]Can't save: %s ", stripBraces(err.Error())))
+		return
+	}
+
+	a.closeEditor()
+	a.status.SetText(fmt.Sprintf("[yellow]Saved %s", stripBraces(s.title)))
+}
+
+func (a *Application) confirmDelete(station Station) {
+	title := stripPlayCount(station.title)
+
+	a.confirm.ClearButtons().
+		SetText(fmt.Sprintf("Delete %s?", title)).
+		AddButtons([]string{"Delete", "Cancel"}).
+		SetDoneFunc(func(_ int, label string) {
+			a.pages.HidePage(a.pageNames[Confirm])
+			a.app.SetFocus(a.pages)
+
+			if label != "Delete" {
+				return
+			}
+
+			if err := a.saveStation(station.url, nil); err != nil {
+				log.Println(err)
+				a.status.SetText(fmt.Sprintf("[red]Can't delete %s: %s", stripBraces(title), stripBraces(err.Error())))
+				return
+			}
+			a.status.SetText(fmt.Sprintf("[yellow]Deleted %s", stripBraces(title)))
+		}).
+		SetFocus(1)
+
+	a.pages.ShowPage(a.pageNames[Confirm])
+	a.app.SetFocus(a.confirm)
+}
+
+// selectedStation returns the station selected on the main page.
+func (a *Application) selectedStation() (Station, bool) {
+	if name, _ := a.pages.GetFrontPage(); name != a.pageNames[Main] {
+		return Station{}, false
+	}
+
+	i := a.stationsList.GetCurrentItem() - a.calculateStationListOffset()
+	if i < 0 || i >= len(a.listedStations) {
+		return Station{}, false
+	}
+
+	return a.listedStations[i], true
+}
+
+// editedFile returns the file the stations are saved to: the first local
+// file of the sources, or the user stations file. It also returns the sources
+// to load once the file is saved, and whether the file is yet to take over
+// the built-in stations.
+func (a *Application) editedFile() (string, []string, bool) {
+	sources := a.sources
+	if len(sources) == 0 {
+		sources = defaultSources()
+	}
+
+	if files := localSources(sources); len(files) > 0 {
+		return files[0], sources, false
+	}
+
+	file := userStationsFile()
+	if slices.Equal(sources, []string{DefaultSource}) {
+		_, err := os.Stat(file)
+		return file, []string{file}, os.IsNotExist(err)
+	}
+	return file, append(slices.Clone(sources), file), false
+}
+
+// editedStation returns the station as it is in the edited file, without
+// the tags added by merging the sources.
+func (a *Application) editedStation(station Station) (Station, error) {
+	file, _, builtIn := a.editedFile()
+	if builtIn {
+		// the listed station may be a favorite with its play count
+		if i := slices.IndexFunc(a.stations, func(s Station) bool { return s.url == station.url }); i >= 0 {
+			return a.stations[i], nil
+		}
+		station.title = stripPlayCount(station.title)
+		return station, nil
+	}
+
+	stations, _, err := loadStations(file)
+	if err != nil && !os.IsNotExist(err) {
+		return Station{}, err
+	}
+
+	for _, s := range stations {
+		if s.url == station.url {
+			return s, nil
+		}
+	}
+
+	return Station{}, fmt.Errorf("%w %s", errNotInFile, filepath.Base(file))
+}
+
+// saveStation replaces the station with the URL in the edited file, adds the
+// station when the URL is empty or deletes it when the station is nil, then
+// reloads the stations. It must be called on the UI goroutine.
+func (a *Application) saveStation(url string, station *Station) error {
+	file, sources, builtIn := a.editedFile()
+
+	if builtIn {
+		// the user stations file takes over the built-in stations
+		if err := writeStationsCSV(file, stationColumns[:3], a.stations); err != nil {
+			return err
+		}
+	}
+
+	if err := editStationsFile(file, url, station); err != nil {
+		return err
+	}
+
+	stations, notes, err := mergeStations(sources)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(a.sources, file) {
+		go a.watchStations(sources, []string{file}, stationsPollInterval)
+	}
+	a.sources = sources
+
+	a.reloadStations(stations)
+	a.ShowNotes(notes)
+
+	return nil
+}
+
+// editStationsFile replaces the station with the URL in a CSV stations file,
+// deletes it when the station is nil or appends the station when the URL is
+// empty. Other rows and columns are kept as they are.
+func editStationsFile(file, url string, station *Station) error {
+	if format := stationsFormat("", file); format != "csv" {
+		return fmt.Errorf("only CSV stations files can be edited, %s is %s", filepath.Base(file),
+			strings.ToUpper(format))
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	var header []string
+	if len(records) == 0 {
+		header = stationColumns[:3]
+	} else if isStationsHeader(records[0]) {
+		header, records = records[0], records[1:]
+	}
+
+	i := -1
+	if url != "" {
+		i = slices.IndexFunc(records, func(r []string) bool {
+			stations := parseStations([][]string{r})
+			if header != nil {
+				stations = parseStations([][]string{header, r})
+			}
+			return len(stations) > 0 && stations[0].url == url
+		})
+		if i < 0 {
+			return fmt.Errorf("%w %s", errNotInFile, filepath.Base(file))
+		}
+	}
+
+	if header == nil && station != nil {
+		// a file without a header only has a title, a URL and tags, so it
+		// gets a header when the station has more to keep
+		if h, _ := stationRecord(stationColumns[:3], nil, *station); len(h) > 3 {
+			header = positionalHeader(records)
+		}
+	}
+
+	switch {
+	case station == nil:
+		records = slices.Delete(records, i, i+1)
+	case i < 0:
+		var row []string
+		header, row = stationRecord(header, nil, *station)
+		records = append(records, row)
+	default:
+		header, records[i] = stationRecord(header, records[i], *station)
+	}
+
+	return writeStationsCSV(file, header, nil, records...)
+}
+
+// positionalHeader names the columns of a file without a header, the ones
+// after the tags are named extra1, extra2 and so on.
+func positionalHeader(records [][]string) []string {
+	header := slices.Clone(stationColumns[:3])
+	for _, r := range records {
+		for len(header) < len(r) {
+			header = append(header, fmt.Sprintf("extra%d", len(header)-2))
+		}
+	}
+	return header
+}
+
+// stationRecord sets the fields of the station in the CSV row. A file with a
+// header gets the columns it misses, one without a header only has a title,
+// a URL and tags.
+func stationRecord(header, row []string, s Station) ([]string, []string) {
+	fields := map[string]string{
+		"title":       s.title,
+		"url":         strings.Join(s.streams(), "|"),
+		"tags":        strings.Join(s.tags, ";"),
+		"homepage":    s.homepage,
+		"country":     s.country,
+		"language":    s.language,
+		"codec":       s.codec,
+		"logo":        s.logo,
+		"description": s.description,
+		"uuid":        s.uuid,
+	}
+	if s.bitrate > 0 {
+		fields["bitrate"] = strconv.Itoa(s.bitrate)
+	}
+	if s.volumeOffset != 0 {
+		fields["volume"] = strconv.Itoa(s.volumeOffset)
+	}
+
+	if header == nil {
+		row = slices.Clone(row)
+		for len(row) < 3 {
+			row = append(row, "")
+		}
+		row[0], row[1], row[2] = fields["title"], fields["url"], fields["tags"]
+		return nil, row
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[columnName(name)] = i
+	}
+
+	for _, name := range stationColumns {
+		if _, ok := columns[name]; !ok && fields[name] != "" {
+			columns[name] = len(header)
+			header = append(slices.Clone(header), name)
+		}
+	}
+
+	row = slices.Clone(row)
+	for len(row) < len(header) {
+		row = append(row, "")
+	}
+
+	for name, i := range columns {
+		if value, ok := fields[name]; ok {
+			row[i] = value
+		}
+	}
+
+	return header, row
+}
+
+// writeStationsCSV writes the stations after the records, with a header when
+// it is given.
+func writeStationsCSV(file string, header []string, stations []Station, records ...[]string) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if header != nil {
+		w.Write(header)
+	}
+
+	for _, r := range records {
+		w.Write(r)
+	}
+
+	for _, s := range stations {
+		_, row := stationRecord(nil, nil, s)
+		w.Write(row)
+	}
+
+	if w.Flush(); w.Error() != nil {
+		return w.Error()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, buf.Bytes(), 0644)
+}
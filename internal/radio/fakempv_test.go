@@ -0,0 +1,233 @@
+//go:build !windows
+
+package radio
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMPVEnv makes the test binary act as a stand-in mpv process, see TestMain.
+const fakeMPVEnv = "GORADION_FAKE_MPV"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeMPVEnv) == "1" {
+		// The IPC server lives in the test process, the stand-in only has to
+		// stay alive until it is killed, like an idle mpv would.
+		select {}
+	}
+
+	InitLog(false)
+	os.Exit(m.Run())
+}
+
+// fakeMPV is an in-process mpv JSON IPC server.
+type fakeMPV struct {
+	sync.Mutex
+	t        *testing.T
+	socket   string
+	listener net.Listener
+	conns    []net.Conn
+	props    map[string]any
+	failing  map[string]string
+	commands chan []any
+}
+
+func newFakeMPV(t *testing.T) *fakeMPV {
+	t.Helper()
+
+	// Unix socket paths are limited to ~100 bytes, t.TempDir() can be longer.
+	dir, err := os.MkdirTemp("", "goradion")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeMPV{
+		t:        t,
+		socket:   filepath.Join(dir, "mpv.sock"),
+		props:    make(map[string]any),
+		failing:  make(map[string]string),
+		commands: make(chan []any, 100),
+	}
+
+	if f.listener, err = net.Listen("unix", f.socket); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		f.listener.Close()
+		f.dropConnections()
+		os.RemoveAll(dir)
+	})
+
+	go f.serve()
+
+	return f
+}
+
+// newTestPlayer starts a Player with an mpv backend talking to a fake mpv.
+func newTestPlayer(t *testing.T) (*Player, *fakeMPV) {
+	t.Helper()
+	t.Setenv(fakeMPVEnv, "1")
+
+	f := newFakeMPV(t)
+	b := newMPVBackend(f.socket)
+	b.binary = os.Args[0]
+
+	p := NewPlayer(b)
+	p.Start()
+
+	t.Cleanup(func() {
+		go func() {
+			for range p.Info {
+			}
+		}()
+		p.Quit()
+		b.Lock()
+		b.cmd.Process.Kill()
+		b.Unlock()
+	})
+
+	return p, f
+}
+
+func (f *fakeMPV) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		f.Lock()
+		f.conns = append(f.conns, conn)
+		f.Unlock()
+
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeMPV) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+
+		var req struct {
+			Command   []any `json:"command"`
+			RequestID int   `json:"request_id"`
+		}
+
+		if err := json.Unmarshal(line, &req); err != nil || len(req.Command) == 0 {
+			f.t.Errorf("fake mpv got a malformed command: %s", line)
+			continue
+		}
+
+		name, _ := req.Command[0].(string)
+		reply := map[string]any{"request_id": req.RequestID, "error": "success", "data": nil}
+
+		f.Lock()
+		if e, ok := f.failing[name]; ok {
+			reply["error"] = e
+		} else {
+			switch name {
+			case "set_property":
+				f.props[req.Command[1].(string)] = req.Command[2]
+			case "get_property":
+				reply["data"] = f.props[req.Command[1].(string)]
+			}
+		}
+		f.Unlock()
+
+		f.write(conn, reply)
+		f.commands <- req.Command
+
+		if name == "quit" {
+			f.dropConnections()
+		}
+	}
+}
+
+func (f *fakeMPV) write(conn net.Conn, msg map[string]any) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	conn.Write(append(data, '\n'))
+}
+
+// emit sends an event to every connected client.
+func (f *fakeMPV) emit(event map[string]any) {
+	f.Lock()
+	defer f.Unlock()
+
+	for _, c := range f.conns {
+		f.write(c, event)
+	}
+}
+
+func (f *fakeMPV) playbackRestart() {
+	f.emit(map[string]any{"event": "playback-restart"})
+}
+
+func (f *fakeMPV) endFile(reason string) {
+	f.emit(map[string]any{"event": "end-file", "reason": reason})
+}
+
+func (f *fakeMPV) propertyChange(name string, data any) {
+	f.emit(map[string]any{"event": "property-change", "id": 1, "name": name, "data": data})
+}
+
+func (f *fakeMPV) metadata(meta map[string]any) {
+	f.propertyChange("filtered-metadata", meta)
+}
+
+func (f *fakeMPV) bitrate(bps float64) {
+	f.propertyChange("audio-bitrate", bps)
+}
+
+// fail makes every following command of the given name reply with an error.
+func (f *fakeMPV) fail(command, err string) {
+	f.Lock()
+	f.failing[command] = err
+	f.Unlock()
+}
+
+// dropConnections simulates mpv going away.
+func (f *fakeMPV) dropConnections() {
+	f.Lock()
+	defer f.Unlock()
+
+	for _, c := range f.conns {
+		c.Close()
+	}
+	f.conns = nil
+}
+
+// waitCommand returns the next received command with the given name.
+func (f *fakeMPV) waitCommand(name string, args ...any) []any {
+	f.t.Helper()
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case cmd := <-f.commands:
+			if cmd[0] == name && (len(args) == 0 || slices.Equal(cmd[1:], args)) {
+				return cmd
+			}
+		case <-timeout:
+			f.t.Fatalf("fake mpv didn't receive %s %v", name, args)
+			return nil
+		}
+	}
+}
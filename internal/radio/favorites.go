@@ -14,6 +14,10 @@ const minPlays = 1
 const maxFavs = int('z' - 'a' + 1)
 
 func getFavoritesFile() string {
+	return getConfigFile("favorites.json")
+}
+
+func getConfigFile(name string) string {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		home = "."
@@ -28,7 +32,7 @@ func getFavoritesFile() string {
 		configDir = filepath.Join(home, ".config", "goradion")
 	}
 
-	return filepath.Join(configDir, "favorites.json")
+	return filepath.Join(configDir, name)
 }
 
 type FavoriteStation struct {
@@ -45,18 +49,10 @@ type Favorites struct {
 }
 
 func NewFavorites(stations []Station) *Favorites {
-	availableStations := make(map[string]bool)
-	stationsByURL := make(map[string]Station)
-	for _, station := range stations {
-		availableStations[station.url] = true
-		stationsByURL[station.url] = station
-	}
-
 	favorites := &Favorites{
-		Stations:          make(map[string]*FavoriteStation),
-		availableStations: availableStations,
-		stationsByURL:     stationsByURL,
+		Stations: make(map[string]*FavoriteStation),
 	}
+	favorites.setStations(stations)
 
 	data, err := os.ReadFile(getFavoritesFile())
 	if err != nil {
@@ -69,6 +65,16 @@ func NewFavorites(stations []Station) *Favorites {
 	return favorites
 }
 
+// setStations sets the stations that can be played from the favorites.
+func (f *Favorites) setStations(stations []Station) {
+	f.availableStations = make(map[string]bool)
+	f.stationsByURL = make(map[string]Station)
+	for _, station := range stations {
+		f.availableStations[station.url] = true
+		f.stationsByURL[station.url] = station
+	}
+}
+
 func (f *Favorites) save() error {
 	favFile := getFavoritesFile()
 	os.MkdirAll(filepath.Dir(favFile), 0755)
@@ -136,16 +142,14 @@ func (f *Favorites) getFavoriteStations() []Station {
 			break
 		}
 
-		title := fav.Title
-		if currentStation, ok := f.stationsByURL[fav.URL]; ok {
-			title = currentStation.title
+		station, ok := f.stationsByURL[fav.URL]
+		if !ok {
+			station = Station{title: fav.Title, url: fav.URL}
 		}
 
-		stations = append(stations, Station{
-			title: fmt.Sprintf("%s [gray](%d)[-]", title, fav.PlayCount),
-			url:   fav.URL,
-			tags:  []string{favoritesTag},
-		})
+		station.title = fmt.Sprintf("%s [gray](%d)[-]", station.title, fav.PlayCount)
+		station.tags = []string{favoritesTag}
+		stations = append(stations, station)
 	}
 
 	return stations
@@ -0,0 +1,31 @@
+package radio
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFavoriteStations(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	station := Station{title: "One", url: "http://one/", urls: []string{"http://one/", "http://one/b"}, tags: []string{"Jazz"},
+		country: "LT", volumeOffset: -10, uuid: "u"}
+
+	f := NewFavorites([]Station{station})
+	f.track(station)
+
+	stations := f.getFavoriteStations()
+	if len(stations) != 1 {
+		t.Fatalf("got %d favorites, want 1", len(stations))
+	}
+
+	fav := stations[0]
+	if fav.title != "One [gray](1)[-]" || !slices.Equal(fav.tags, []string{favoritesTag}) {
+		t.Errorf("got %+v", fav)
+	}
+	if !slices.Equal(fav.urls, station.urls) || fav.country != "LT" || fav.volumeOffset != -10 || fav.uuid != "u" {
+		t.Errorf("the favorite lost the details of the station: %+v", fav)
+	}
+}
@@ -0,0 +1,271 @@
+package radio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ffplay can't change the volume of a running stream, so a new process is
+// spawned once the volume settles for this long.
+const ffplayVolumeDelay = 500 * time.Millisecond
+
+const ffmpegStopTimeout = 5 * time.Second
+
+var (
+	reFFplayAudio   = regexp.MustCompile(`Stream #\d+:\d+.*: Audio: (\w+)`)
+	reFFplayBitrate = regexp.MustCompile(`(\d+) kb/s`)
+	reFFplayMeta    = regexp.MustCompile(`^\s*(StreamTitle|icy-title|title|artist|TITLE|ARTIST)\s*: (.*)$`)
+)
+
+type ffplayBackend struct {
+	sync.Mutex
+	cmd         *exec.Cmd
+	recorder    *exec.Cmd
+	recorded    chan struct{}
+	url         string
+	volume      int
+	volumeTimer *time.Timer
+	events      chan Event
+}
+
+func newFFplayBackend() *ffplayBackend {
+	return &ffplayBackend{
+		volume: defaultVolume,
+		events: make(chan Event),
+	}
+}
+
+func (f *ffplayBackend) Start() error {
+	if _, err := exec.LookPath("ffplay"); err != nil {
+		return fmt.Errorf("%w\nPlease make sure 'ffplay' is available, it is a part of FFmpeg.\n"+
+			"Install it using your package manager or visit https://ffmpeg.org for more info.", err)
+	}
+	return nil
+}
+
+func (f *ffplayBackend) Events() <-chan Event {
+	return f.events
+}
+
+func (f *ffplayBackend) Load(url string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	log.Printf("loading %s\n", url)
+	f.url = url
+	return f.spawn()
+}
+
+func (f *ffplayBackend) Stop() error {
+	f.Lock()
+	defer f.Unlock()
+
+	f.url = ""
+	f.kill()
+	return nil
+}
+
+func (f *ffplayBackend) SetVolume(volume int) error {
+	f.Lock()
+	defer f.Unlock()
+
+	log.Printf("setting volume %d\n", volume)
+	f.volume = volume
+
+	if f.volumeTimer != nil {
+		f.volumeTimer.Stop()
+	}
+
+	f.volumeTimer = time.AfterFunc(ffplayVolumeDelay, func() {
+		f.Lock()
+		defer f.Unlock()
+
+		if f.url != "" {
+			f.spawn()
+		}
+	})
+
+	return nil
+}
+
+// Record runs ffmpeg next to ffplay, copying the stream without re-encoding.
+func (f *ffplayBackend) Record(path string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.recorder != nil {
+		// ffmpeg finalizes the file on interrupt, which Windows doesn't support
+		if err := f.recorder.Process.Signal(os.Interrupt); err != nil {
+			f.recorder.Process.Kill()
+		}
+
+		// the file is complete once ffmpeg exits
+		select {
+		case <-f.recorded:
+		case <-time.After(ffmpegStopTimeout):
+			f.recorder.Process.Kill()
+		}
+
+		f.recorder = nil
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	if f.url == "" {
+		return errNothingToRecord
+	}
+
+	cmd := exec.Command(
+		"ffmpeg",
+		"-nostdin",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-i", f.url,
+		"-map", "0:a",
+		"-c", "copy",
+		"-y", path,
+	)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	f.recorder = cmd
+	f.recorded = make(chan struct{})
+
+	go func(done chan struct{}) {
+		cmd.Wait()
+		close(done)
+	}(f.recorded)
+
+	return nil
+}
+
+func (f *ffplayBackend) Quit() error {
+	log.Println("quitting ffplay")
+	f.Record("")
+	return f.Stop()
+}
+
+// spawn replaces the running ffplay process with a new one playing f.url.
+func (f *ffplayBackend) spawn() error {
+	f.kill()
+
+	cmd := exec.Command(
+		"ffplay",
+		"-nodisp",
+		"-autoexit",
+		"-vn",
+		"-hide_banner",
+		"-loglevel", "info",
+		"-volume", strconv.Itoa(f.volume),
+		f.url,
+	)
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	f.cmd = cmd
+
+	go f.readEvents(cmd, bufio.NewScanner(stderr))
+
+	return nil
+}
+
+func (f *ffplayBackend) kill() {
+	if f.cmd == nil {
+		return
+	}
+
+	cmd := f.cmd
+	f.cmd = nil
+	cmd.Process.Signal(os.Kill)
+}
+
+func (f *ffplayBackend) readEvents(cmd *exec.Cmd, scanner *bufio.Scanner) {
+	// ffplay redraws its status line with a carriage return.
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
+
+	meta := make(map[string]any)
+	started := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if m := reFFplayAudio.FindStringSubmatch(line); m != nil {
+			f.emit(cmd, Event{Kind: EventCodec, Codec: m[1]})
+			if m := reFFplayBitrate.FindStringSubmatch(line); m != nil {
+				br, _ := strconv.Atoi(m[1])
+				f.emit(cmd, Event{Kind: EventBitrate, Bitrate: br})
+			}
+			if !started {
+				started = true
+				f.emit(cmd, Event{Kind: EventPlaying})
+			}
+			continue
+		}
+
+		if m := reFFplayMeta.FindStringSubmatch(line); m != nil {
+			switch strings.ToLower(m[1]) {
+			case "streamtitle", "icy-title":
+				meta["icy-title"] = strings.TrimSpace(m[2])
+			case "title":
+				meta["Title"] = strings.TrimSpace(m[2])
+			case "artist":
+				meta["Artist"] = strings.TrimSpace(m[2])
+			}
+			f.emit(cmd, Event{Kind: EventMetadata, Metadata: maps.Clone(meta)})
+			continue
+		}
+
+		log.Println(line)
+	}
+
+	err := cmd.Wait()
+
+	reason := "eof"
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		reason = "error"
+	}
+
+	f.emit(cmd, Event{Kind: EventEndFile, Reason: reason})
+}
+
+// emit forwards events of the current process only, so that the output of
+// a killed process doesn't leak into the state of its replacement.
+func (f *ffplayBackend) emit(cmd *exec.Cmd, e Event) {
+	f.Lock()
+	current := f.cmd == cmd
+	f.Unlock()
+
+	if current {
+		f.events <- e
+	}
+}
@@ -0,0 +1,187 @@
+package radio
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const maxHistory = 1000
+
+type HistoryEntry struct {
+	Time    time.Time `json:"time"`
+	Station string    `json:"station"`
+	URL     string    `json:"url"`
+	Song    string    `json:"song"`
+}
+
+// SongHistory keeps the songs that were played, oldest first.
+type SongHistory struct {
+	sync.Mutex
+	Entries []HistoryEntry `json:"entries"`
+}
+
+func getHistoryFile() string {
+	return getConfigFile("history.json")
+}
+
+func NewSongHistory() *SongHistory {
+	history := &SongHistory{}
+
+	data, err := os.ReadFile(getHistoryFile())
+	if err != nil {
+		return history
+	}
+
+	if err := json.Unmarshal(data, history); err != nil {
+		log.Printf("Failed to unmarshal history: %v", err)
+	}
+	return history
+}
+
+func (h *SongHistory) save() error {
+	historyFile := getHistoryFile()
+	os.MkdirAll(filepath.Dir(historyFile), 0755)
+
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(historyFile, data, 0644)
+}
+
+func (h *SongHistory) add(entry HistoryEntry) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.Entries = append(h.Entries, entry)
+	if len(h.Entries) > maxHistory {
+		h.Entries = slices.Clone(h.Entries[len(h.Entries)-maxHistory:])
+	}
+
+	if err := h.save(); err != nil {
+		log.Printf("Failed to save history: %v", err)
+	}
+}
+
+// recent returns the entries of the stations matching the filter, newest
+// first.
+func (h *SongHistory) recent(station string) []HistoryEntry {
+	h.Lock()
+	defer h.Unlock()
+
+	station = strings.ToLower(station)
+	var entries []HistoryEntry
+
+	for i := len(h.Entries) - 1; i >= 0; i-- {
+		if strings.Contains(strings.ToLower(h.Entries[i].Station), station) {
+			entries = append(entries, h.Entries[i])
+		}
+	}
+
+	return entries
+}
+
+func (a *Application) setupHistoryPage() {
+	a.historyFilter = tview.NewInputField().
+		SetLabel("Station: ").
+		SetFieldWidth(0).
+		SetChangedFunc(a.updateHistoryList)
+
+	a.historyFilter.SetFieldBackgroundColor(tcell.ColorBlack)
+	a.historyFilter.SetBackgroundColor(tcell.ColorDefault)
+	a.historyFilter.SetLabelColor(tcell.ColorGreen)
+
+	a.historyFilter.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEnter, tcell.KeyDown, tcell.KeyTab:
+			a.app.SetFocus(a.historyList)
+			return nil
+		}
+		return event
+	})
+
+	a.historyList = newList()
+	a.historyList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyUp && a.historyList.GetCurrentItem() == 0 {
+			a.app.SetFocus(a.historyFilter)
+			return nil
+		}
+		return event
+	})
+
+	historyFlex := tview.NewFlex().
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(a.historyFilter, 1, 0, false).
+			AddItem(a.historyList, 0, 100, true).
+			AddItem(a.newStatusFlex(), 0, 1, false), 0, 1, true)
+
+	a.pages.AddPage(a.pageNames[History], historyFlex, true, false)
+}
+
+func (a *Application) showHistory() {
+	a.historyFilter.SetText("")
+	a.updateHistoryList("")
+	a.show(History)
+	a.app.SetFocus(a.historyFilter)
+}
+
+func (a *Application) updateHistoryList(filter string) {
+	current := a.historyList.GetCurrentItem()
+	count := a.historyList.GetItemCount()
+
+	a.historyList.Clear()
+
+	entries := a.history.recent(filter)
+
+	for _, entry := range entries {
+		text := fmt.Sprintf("[gray]%s[-] %s [gray]|[-] %s",
+			entry.Time.Format("Jan 02 15:04"), stripBraces(entry.Station), stripBraces(entry.Song))
+
+		a.historyList.AddItem(text, "", 0, func() {
+			a.playFromHistory(entry)
+		})
+	}
+
+	if len(entries) == 0 {
+		a.historyList.AddItem("No songs were found", "", rune('!'), nil)
+		return
+	}
+
+	// keep the selected entry when new songs are added on top
+	if count > 1 && current > 0 {
+		a.historyList.SetCurrentItem(current + len(entries) - count)
+	}
+}
+
+// refreshHistory must be called on the UI goroutine.
+func (a *Application) refreshHistory() {
+	if name, _ := a.pages.GetFrontPage(); name == a.pageNames[History] {
+		a.updateHistoryList(a.historyFilter.GetText())
+	}
+}
+
+func (a *Application) playFromHistory(entry HistoryEntry) {
+	if entry.URL == a.lastInfo.Url {
+		return
+	}
+
+	station := Station{title: entry.Station, url: entry.URL}
+	for _, s := range a.stations {
+		if s.url == entry.URL {
+			station = s
+			break
+		}
+	}
+
+	go a.togglePlayManual(station)
+}
@@ -0,0 +1,133 @@
+package radio
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+var ExportFormats = []string{"csv", "json", "txt"}
+
+type LikedSong struct {
+	Time    time.Time `json:"time"`
+	Song    string    `json:"song"`
+	Station string    `json:"station"`
+	URL     string    `json:"url"`
+}
+
+type LikedSongs struct {
+	sync.Mutex
+	Songs []LikedSong `json:"songs"`
+}
+
+func getLikedFile() string {
+	return getConfigFile("liked.json")
+}
+
+func NewLikedSongs() *LikedSongs {
+	liked := &LikedSongs{}
+
+	data, err := os.ReadFile(getLikedFile())
+	if err != nil {
+		return liked
+	}
+
+	if err := json.Unmarshal(data, liked); err != nil {
+		log.Printf("Failed to unmarshal liked songs: %v", err)
+	}
+	return liked
+}
+
+func (l *LikedSongs) save() error {
+	likedFile := getLikedFile()
+	os.MkdirAll(filepath.Dir(likedFile), 0755)
+
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(likedFile, data, 0644)
+}
+
+// like adds the song unless it was liked already, it reports whether the
+// song was added.
+func (l *LikedSongs) like(song LikedSong) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if slices.ContainsFunc(l.Songs, func(s LikedSong) bool { return s.Song == song.Song }) {
+		return false, nil
+	}
+
+	l.Songs = append(l.Songs, song)
+	return true, l.save()
+}
+
+// Export writes the liked songs in one of the ExportFormats, txt being an
+// "Artist - Title" list for importing into playlists.
+func (l *LikedSongs) Export(w io.Writer, format string) error {
+	l.Lock()
+	defer l.Unlock()
+
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"time", "artist", "title", "station", "url"})
+		for _, s := range l.Songs {
+			t := newTrackTags(s.Song, s.Station)
+			cw.Write([]string{s.Time.Format(time.RFC3339), t.Artist, t.Title, s.Station, s.URL})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		songs := l.Songs
+		if songs == nil {
+			songs = []LikedSong{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(songs)
+	case "txt":
+		for _, s := range l.Songs {
+			if _, err := fmt.Fprintln(w, s.Song); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown export format %q, use one of %v", format, ExportFormats)
+}
+
+func (a *Application) likeSong() {
+	inf := a.lastInfo
+
+	if inf.Song == "" {
+		a.status.SetText("[red]Nothing to like, no song is playing")
+		return
+	}
+
+	added, err := a.liked.like(LikedSong{
+		Time:    time.Now(),
+		Song:    inf.Song,
+		Station: stripPlayCount(inf.Station),
+		URL:     inf.Url,
+	})
+
+	switch {
+	case err != nil:
+		log.Println(err)
+		a.status.SetText(fmt.Sprintf("[red]Can't save the liked song: %s", err))
+	case !added:
+		a.status.SetText(fmt.Sprintf("Already liked [gray]| [green]%s", stripBraces(inf.Song)))
+	default:
+		a.status.SetText(fmt.Sprintf("[red]♥[-] Liked [gray]| [green]%s", stripBraces(inf.Song)))
+	}
+}
@@ -0,0 +1,365 @@
+package radio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type mpvBackend struct {
+	sync.Mutex
+	binary   string
+	socket   string
+	cmd      *exec.Cmd
+	exited   chan struct{}
+	client   *mpvClient
+	events   chan Event
+	url      string
+	volume   int
+	quitting bool
+}
+
+func newMPVBackend(socket string) *mpvBackend {
+	return &mpvBackend{
+		binary: "mpv",
+		socket: socket,
+		events: make(chan Event),
+		volume: defaultVolume,
+	}
+}
+
+func (m *mpvBackend) Start() error {
+	m.Lock()
+	defer m.Unlock()
+
+	if err := m.spawn(); err != nil {
+		return err
+	}
+
+	go m.supervise()
+
+	return nil
+}
+
+// spawn starts mpv and connects to it, it expects the lock to be held.
+func (m *mpvBackend) spawn() error {
+	cmd := exec.Command(
+		m.binary,
+		"-no-video",
+		"--idle",
+		"--display-tags=Artist,Title,icy-title",
+		"--network-timeout=10",
+		fmt.Sprintf("--volume=%d", m.volume),
+		fmt.Sprintf("--input-ipc-server=%s", m.socket),
+	)
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%w\nPlease make sure 'mpv' is available.\n"+
+			"Install it using your package manager or visit https://mpv.io for more info.", err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		err := cmd.Wait()
+		log.Printf("mpv exited: %v\n", err)
+		close(exited)
+	}()
+
+	var client *mpvClient
+	var err error
+
+	for i := 1; ; i++ {
+		if client, err = dialMPV(m.socket); err == nil {
+			break
+		}
+		if i == 10 {
+			cmd.Process.Kill()
+			return errors.New("mpv failed to start, quitting")
+		}
+		log.Printf("waiting for mpv +%d ms\n", 8<<i)
+		time.Sleep((8 << i) * time.Millisecond)
+	}
+
+	for _, prop := range []string{"filtered-metadata", "audio-bitrate", "audio-codec-name", "pause"} {
+		if _, err := client.Command("observe_property", 1, prop); err != nil {
+			log.Println(err)
+		}
+	}
+
+	m.cmd = cmd
+	m.exited = exited
+	m.client = client
+
+	go m.readEvents(client)
+
+	return nil
+}
+
+// supervise restarts mpv whenever its process exits or the IPC connection
+// is lost, restoring the volume and the stream that was playing.
+func (m *mpvBackend) supervise() {
+	for {
+		m.Lock()
+		cmd, exited, client := m.cmd, m.exited, m.client
+		m.Unlock()
+
+		select {
+		case <-exited:
+		case <-client.Done():
+		}
+
+		m.Lock()
+
+		if m.quitting {
+			m.Unlock()
+			return
+		}
+
+		log.Println("lost mpv, restarting")
+		client.Close()
+		cmd.Process.Kill()
+		<-exited
+
+		for i := 0; ; i++ {
+			err := m.spawn()
+			if err == nil {
+				break
+			}
+
+			log.Println(err)
+			m.Unlock()
+			time.Sleep(min(time.Second<<i, time.Minute))
+			m.Lock()
+
+			if m.quitting {
+				m.Unlock()
+				return
+			}
+		}
+
+		if m.url != "" {
+			if _, err := m.client.Command("loadfile", m.url); err != nil {
+				log.Println(err)
+			}
+		}
+
+		m.Unlock()
+
+		m.events <- Event{Kind: EventRestarted}
+	}
+}
+
+func (m *mpvBackend) Events() <-chan Event {
+	return m.events
+}
+
+func (m *mpvBackend) Load(url string) error {
+	log.Printf("loading %s\n", url)
+	if err := m.command("loadfile", url); err != nil {
+		return err
+	}
+
+	m.Lock()
+	m.url = url
+	m.Unlock()
+
+	return nil
+}
+
+func (m *mpvBackend) Stop() error {
+	m.Lock()
+	m.url = ""
+	m.Unlock()
+
+	return m.command("stop")
+}
+
+func (m *mpvBackend) SetVolume(volume int) error {
+	log.Printf("setting volume %d\n", volume)
+	if err := m.command("set_property", "volume", volume); err != nil {
+		return err
+	}
+
+	m.Lock()
+	m.volume = volume
+	m.Unlock()
+
+	return nil
+}
+
+func (m *mpvBackend) Record(path string) error {
+	return m.command("set_property", "stream-record", path)
+}
+
+func (m *mpvBackend) Quit() error {
+	log.Println("quitting mpv")
+
+	m.Lock()
+	m.quitting = true
+	m.Unlock()
+
+	err := m.command("quit", 9)
+	if errors.Is(err, errMPVClosed) {
+		// mpv may close the connection before replying to quit
+		err = nil
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if err != nil && m.cmd != nil {
+		log.Println("mpv failed to quit via socket")
+		m.cmd.Process.Signal(os.Kill)
+		<-m.exited
+	}
+
+	return err
+}
+
+// StreamInfo asks mpv about the stream, the properties mpv doesn't have for
+// the stream are left out.
+func (m *mpvBackend) StreamInfo() (StreamInfo, error) {
+	m.Lock()
+	client := m.client
+	m.Unlock()
+
+	if client == nil {
+		return StreamInfo{}, errMPVClosed
+	}
+
+	property := func(name string) any {
+		data, err := client.Command("get_property", name)
+		if err != nil {
+			log.Println(err)
+		}
+		return data
+	}
+
+	info := StreamInfo{ICY: make(map[string]string)}
+	info.URL, _ = property("stream-open-filename").(string)
+	info.Format, _ = property("file-format").(string)
+	info.Codec, _ = property("audio-codec").(string)
+
+	if params, ok := property("audio-params").(map[string]any); ok {
+		if v, ok := params["samplerate"].(float64); ok {
+			info.SampleRate = int(v)
+		}
+		if v, ok := params["channel-count"].(float64); ok {
+			info.Channels = int(v)
+		}
+		info.Layout, _ = params["hr-channels"].(string)
+	}
+
+	if v, ok := property("audio-bitrate").(float64); ok {
+		info.Bitrate = int(math.Round(v / 1000.0))
+	}
+
+	if meta, ok := property("metadata").(map[string]any); ok {
+		for k, v := range meta {
+			if s, ok := v.(string); ok && strings.HasPrefix(strings.ToLower(k), "icy-") && s != "" {
+				info.ICY[strings.ToLower(k)] = s
+			}
+		}
+	}
+
+	if cache, ok := property("demuxer-cache-state").(map[string]any); ok {
+		if v, ok := cache["cache-duration"].(float64); ok {
+			info.Buffered = time.Duration(v * float64(time.Second))
+		}
+		if v, ok := cache["fw-bytes"].(float64); ok {
+			info.BufferSize = int64(v)
+		}
+	}
+
+	info.Buffering, _ = property("paused-for-cache").(bool)
+
+	return info, nil
+}
+
+func (m *mpvBackend) readEvents(client *mpvClient) {
+	for rsp := range client.Events() {
+		if eventIs(rsp, "property-change") && nameIs(rsp, "audio-bitrate") {
+			br, ok := rsp["data"].(float64)
+			if ok {
+				m.events <- Event{Kind: EventBitrate, Bitrate: int(math.Round(br / 1000.0))}
+			}
+		} else {
+			log.Println(rsp)
+		}
+
+		// MPV pauses (at least on Mac) after switching off bluetooth headphones (resuming playback on main soundcard).
+		// Let's permanently disable pause, we don't need it.
+		if eventIs(rsp, "property-change") && nameIs(rsp, "pause") {
+			if pause, ok := rsp["data"].(bool); ok && pause {
+				if _, err := client.Command("set_property", "pause", false); err != nil {
+					log.Println(err)
+				}
+			}
+		}
+
+		if eventIs(rsp, "property-change") && nameIs(rsp, "audio-codec-name") {
+			if codec, ok := rsp["data"].(string); ok {
+				m.events <- Event{Kind: EventCodec, Codec: codec}
+			}
+		}
+
+		if eventIs(rsp, "playback-restart") {
+			m.events <- Event{Kind: EventPlaying}
+		}
+
+		if eventIs(rsp, "property-change") && nameIs(rsp, "filtered-metadata") {
+			meta, ok := rsp["data"].(map[string]any)
+			if ok {
+				m.events <- Event{Kind: EventMetadata, Metadata: meta}
+			}
+		}
+
+		if eventIs(rsp, "end-file") {
+			reason, _ := rsp["reason"].(string)
+			fileError, _ := rsp["file_error"].(string)
+			m.events <- Event{Kind: EventEndFile, Reason: reason, Error: fileError}
+		}
+	}
+}
+
+func (m *mpvBackend) command(args ...any) error {
+	m.Lock()
+	client := m.client
+	m.Unlock()
+
+	if client == nil {
+		return errMPVClosed
+	}
+
+	_, err := client.Command(args...)
+	return err
+}
+
+func unmarshal(data []byte) map[string]any {
+	res := make(map[string]any)
+
+	if err := json.Unmarshal(data, &res); err != nil {
+		log.Println(err)
+	}
+
+	return res
+}
+
+func eventIs(m map[string]any, needle string) bool {
+	return m["event"] != nil && m["event"].(string) == needle
+}
+
+func nameIs(m map[string]any, needle ...string) bool {
+	if m["name"] == nil {
+		return false
+	}
+
+	return slices.Contains(needle, m["name"].(string))
+}
@@ -0,0 +1,187 @@
+package radio
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const mpvCommandTimeout = 5 * time.Second
+
+var errMPVClosed = errors.New("mpv IPC connection is closed")
+
+// mpvClient is a single long-lived connection to the mpv JSON IPC server.
+// Commands are tagged with a request_id and matched against replies, while
+// events are delivered in order through the Events channel.
+type mpvClient struct {
+	sync.Mutex
+	conn    net.Conn
+	nextID  int
+	pending map[int]chan mpvReply
+	closed  bool
+	events  chan map[string]any
+	done    chan struct{}
+}
+
+type mpvReply struct {
+	Error     string `json:"error"`
+	Data      any    `json:"data"`
+	RequestID int    `json:"request_id"`
+}
+
+func dialMPV(socket string) (*mpvClient, error) {
+	conn, err := netDial(socket)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &mpvClient{
+		conn:    conn,
+		pending: make(map[int]chan mpvReply),
+		events:  make(chan map[string]any),
+		done:    make(chan struct{}),
+	}
+
+	go c.read()
+
+	return c, nil
+}
+
+func (c *mpvClient) Events() <-chan map[string]any {
+	return c.events
+}
+
+// Done is closed once the connection to mpv is lost.
+func (c *mpvClient) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *mpvClient) Command(args ...any) (any, error) {
+	c.Lock()
+
+	if c.closed {
+		c.Unlock()
+		return nil, errMPVClosed
+	}
+
+	c.nextID++
+	id := c.nextID
+	reply := make(chan mpvReply, 1)
+	c.pending[id] = reply
+
+	data, err := json.Marshal(map[string]any{"command": args, "request_id": id})
+	if err == nil {
+		_, err = c.conn.Write(append(data, '\n'))
+	}
+
+	if err != nil {
+		delete(c.pending, id)
+		c.Unlock()
+		log.Println(err, args)
+		return nil, err
+	}
+
+	c.Unlock()
+
+	select {
+	case r, ok := <-reply:
+		if !ok {
+			return nil, errMPVClosed
+		}
+		if r.Error != "success" {
+			return nil, fmt.Errorf("mpv %v: %s", args[0], r.Error)
+		}
+		return r.Data, nil
+	case <-time.After(mpvCommandTimeout):
+		c.Lock()
+		delete(c.pending, id)
+		c.Unlock()
+		return nil, fmt.Errorf("mpv %v: no reply in %s", args[0], mpvCommandTimeout)
+	}
+}
+
+func (c *mpvClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *mpvClient) read() {
+	queue := make(chan map[string]any)
+	go c.deliver(queue)
+
+	r := bufio.NewReader(c.conn)
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			log.Println(err)
+			break
+		}
+
+		msg := unmarshal(line)
+
+		if _, ok := msg["event"]; ok {
+			queue <- msg
+			continue
+		}
+
+		var reply mpvReply
+		if err := json.Unmarshal(line, &reply); err != nil || reply.RequestID == 0 {
+			log.Println(msg)
+			continue
+		}
+
+		c.Lock()
+		if ch, ok := c.pending[reply.RequestID]; ok {
+			ch <- reply
+			delete(c.pending, reply.RequestID)
+		}
+		c.Unlock()
+	}
+
+	c.Lock()
+	c.closed = true
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	c.Unlock()
+
+	close(queue)
+	c.conn.Close()
+	close(c.done)
+}
+
+// deliver buffers events, so that a slow consumer never stalls the reading
+// of command replies.
+func (c *mpvClient) deliver(queue <-chan map[string]any) {
+	defer close(c.events)
+
+	var buf []map[string]any
+
+	for {
+		var out chan map[string]any
+		var next map[string]any
+
+		if len(buf) > 0 {
+			out = c.events
+			next = buf[0]
+		}
+
+		select {
+		case e, ok := <-queue:
+			if !ok {
+				for _, e := range buf {
+					c.events <- e
+				}
+				return
+			}
+			buf = append(buf, e)
+		case out <- next:
+			buf = buf[1:]
+		}
+	}
+}
@@ -12,6 +12,6 @@ import (
 
 var socket = fmt.Sprintf(`\\.\pipe\mpv%dsock`, os.Getpid())
 
-func netDial() (net.Conn, error) {
+func netDial(socket string) (net.Conn, error) {
 	return winio.DialPipe(socket, nil)
 }
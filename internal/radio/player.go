@@ -1,13 +1,9 @@
 package radio
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
 	"fmt"
-	"math"
 	"os"
-	"os/exec"
 	"slices"
 	"sync"
 	"time"
@@ -18,26 +14,50 @@ const (
 	buffering     = "Buffering..."
 	stopped       = "Stopped"
 	playing       = "Playing"
+	restarted     = "Player restarted"
 )
 
+// A stream fails this many times in a row before the next stream of the
+// station is tried.
+const failoverAfter = 2
+
+// The delay before retrying to load a station doubles with every retry.
+const retryDelay = time.Second
+
 type Player struct {
 	sync.Mutex
-	Info        chan Info
-	cmd         *exec.Cmd
-	info        *Info
-	retry       *Retry
-	savedVolume int
-	fadeCancel  context.CancelFunc
+	Info         chan Info
+	backend      Backend
+	info         *Info
+	retry        *Retry
+	savedVolume  int
+	fadeCancel   context.CancelFunc
+	record       bool
+	autoRecord   bool
+	splitTracks  bool
+	recordDir    string
+	history      *SongHistory
+	streams      []string
+	stream       int
+	resolving    bool // the events of the previous station are dropped
+	failures     int
+	retryDelay   time.Duration
+	volumeOffset int
 }
 
 type Info struct {
-	Status   string
-	Station  string
-	Song     string
-	PrevSong string
-	Url      string
-	Volume   int
-	Bitrate  int
+	Status    string
+	Station   string
+	Song      string
+	PrevSong  string
+	Url       string
+	Volume    int
+	Bitrate   int
+	Codec     string
+	Stream    string
+	Mirror    int
+	Mirrors   int
+	Recording *Recording
 }
 
 type Retry struct {
@@ -46,46 +66,49 @@ type Retry struct {
 	count  uint64
 }
 
-func NewPlayer() *Player {
+func NewPlayer(backend Backend) *Player {
 	return &Player{
-		retry: new(Retry),
-		Info:  make(chan Info),
+		backend:    backend,
+		retry:      new(Retry),
+		retryDelay: retryDelay,
+		recordDir:  ".",
+		Info:       make(chan Info),
 		info: &Info{
 			Volume: defaultVolume,
 		},
 	}
 }
 
+// SetRecording sets the directory for recordings, with always set every
+// station is recorded once it starts playing, with split every track is
+// recorded into a file of its own.
+func (p *Player) SetRecording(dir string, always, split bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.recordDir = dir
+	p.autoRecord = always
+	p.record = always
+	p.splitTracks = split
+}
+
+// SetHistory sets the history the played songs are added to.
+func (p *Player) SetHistory(history *SongHistory) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.history = history
+}
+
 func (p *Player) Start() {
 	p.Lock()
-	p.cmd = exec.Command(
-		"mpv",
-		"-no-video",
-		"--idle",
-		"--display-tags=Artist,Title,icy-title",
-		"--network-timeout=10",
-		fmt.Sprintf("--volume=%d", defaultVolume),
-		fmt.Sprintf("--input-ipc-server=%s", socket),
-	)
-
-	if err := p.cmd.Start(); err != nil {
+	if err := p.backend.Start(); err != nil {
 		fmt.Println(err)
-		fmt.Println("Please make sure 'mpv' is available.")
-		fmt.Println("Install it using your package manager or visit https://mpv.io for more info.")
 		os.Exit(1)
 	}
-
-	for i := 1; !mpvIsListening() && i <= 10; i++ {
-		if i == 10 {
-			fmt.Println("mpv failed to start, quitting")
-			os.Exit(1)
-		}
-		log.Printf("waiting for mpv +%d ms\n", 8<<i)
-		time.Sleep((8 << i) * time.Millisecond)
-	}
 	p.Unlock()
 
-	go p.readMPVEvents()
+	go p.readEvents()
 }
 
 func (p *Player) VolumeUp() {
@@ -100,9 +123,10 @@ func (p *Player) VolumeUp() {
 		return
 	}
 
-	log.Printf("setting volume %d\n", p.info.Volume+5)
-	cmd := fmt.Sprintf(`{"command": ["set_property", "volume", %d]}%s`, p.info.Volume+5, "\n")
-	writeToMPV([]byte(cmd))
+	if err := p.setBackendVolume(p.info.Volume + 5); err != nil {
+		log.Println(err)
+		return
+	}
 	p.info.Volume += 5
 }
 
@@ -118,9 +142,10 @@ func (p *Player) VolumeDn() {
 		return
 	}
 
-	log.Printf("setting volume %d\n", p.info.Volume-5)
-	cmd := fmt.Sprintf(`{"command": ["set_property", "volume", %d]}%s`, p.info.Volume-5, "\n")
-	writeToMPV([]byte(cmd))
+	if err := p.setBackendVolume(p.info.Volume - 5); err != nil {
+		log.Println(err)
+		return
+	}
 	p.info.Volume -= 5
 }
 
@@ -148,8 +173,9 @@ func (p *Player) FadeOut(ctx context.Context, duration time.Duration) {
 
 		p.Lock()
 		p.info.Volume = newVolume
-		cmd := fmt.Sprintf(`{"command": ["set_property", "volume", %d]}%s`, newVolume, "\n")
-		writeToMPV([]byte(cmd))
+		if err := p.setBackendVolume(newVolume); err != nil {
+			log.Println(err)
+		}
 		p.Info <- *p.info
 		p.Unlock()
 
@@ -181,8 +207,9 @@ func (p *Player) FadeIn(ctx context.Context, duration time.Duration) {
 
 		p.Lock()
 		p.info.Volume = newVolume
-		cmd := fmt.Sprintf(`{"command": ["set_property", "volume", %d]}%s`, newVolume, "\n")
-		writeToMPV([]byte(cmd))
+		if err := p.setBackendVolume(newVolume); err != nil {
+			log.Println(err)
+		}
 		p.Info <- *p.info
 		p.Unlock()
 
@@ -192,7 +219,7 @@ func (p *Player) FadeIn(ctx context.Context, duration time.Duration) {
 	}
 }
 
-func (p *Player) SetVolume(volume int) {
+func (p *Player) SetVolume(volume int) error {
 	p.Lock()
 	defer p.Unlock()
 
@@ -203,10 +230,19 @@ func (p *Player) SetVolume(volume int) {
 		volume = 100
 	}
 
+	if err := p.setBackendVolume(volume); err != nil {
+		return err
+	}
+
 	p.info.Volume = volume
-	cmd := fmt.Sprintf(`{"command": ["set_property", "volume", %d]}%s`, volume, "\n")
-	writeToMPV([]byte(cmd))
 	p.Info <- *p.info
+	return nil
+}
+
+// setBackendVolume applies the volume offset of the station, it expects the
+// lock to be held.
+func (p *Player) setBackendVolume(volume int) error {
+	return p.backend.SetVolume(max(0, min(100, volume+p.volumeOffset)))
 }
 
 func (p *Player) Toggle(station Station) {
@@ -217,10 +253,14 @@ func (p *Player) Toggle(station Station) {
 		return
 	}
 
+	p.stopRecording()
+	p.record = p.autoRecord
+
 	if station.url == p.info.Url {
 		p.Stop()
 		p.info.PrevSong = ""
 		p.info.Url = ""
+		p.streams = nil
 		return
 	}
 
@@ -232,12 +272,53 @@ func (p *Player) Toggle(station Station) {
 	p.retry = &Retry{ctx: ctx, cancel: cancel}
 
 	p.info.Station = station.title
+	p.info.Url = station.url
+	p.info.Stream = ""
+	p.info.Mirror = 0
+	p.info.Mirrors = 0
 	p.info.Status = buffering
 	p.info.Bitrate = 0
+	p.info.Codec = ""
 	p.info.Song = ""
 	p.Info <- *p.info
 
-	p.Load(station.url)
+	if station.volumeOffset != p.volumeOffset {
+		p.volumeOffset = station.volumeOffset
+		if err := p.setBackendVolume(p.info.Volume); err != nil {
+			log.Println(err)
+		}
+	}
+
+	var streams []string
+	if slices.ContainsFunc(station.streams(), func(u string) bool { return playlistKind("", u) != "" }) {
+		// playlists are fetched without blocking the player, the previous
+		// station is stopped first so that it doesn't play as the new one
+		if err := p.backend.Stop(); err != nil {
+			log.Println(err)
+		}
+		p.streams = nil
+		p.resolving = true
+
+		p.Unlock()
+		for _, url := range station.streams() {
+			streams = append(streams, resolveStreams(url)...)
+		}
+		p.Lock()
+
+		if ctx.Err() != nil {
+			return
+		}
+		p.resolving = false
+	} else {
+		streams = station.streams()
+	}
+
+	p.streams = uniqueStrings(streams)
+	p.setStream(0)
+
+	if err := p.Load(station.url); err != nil {
+		p.setStatusError(err)
+	}
 }
 
 func (p *Player) Stop() {
@@ -245,135 +326,195 @@ func (p *Player) Stop() {
 		p.retry.cancel()
 	}
 	log.Printf("stopping %s\n", p.info.Url)
-	cmd := fmt.Sprintf(`{"command": ["stop"]}%s`, "\n")
-	writeToMPV([]byte(cmd))
+	p.resolving = false
+	p.stopRecording()
+	if err := p.backend.Stop(); err != nil {
+		log.Println(err)
+	}
 	p.info.Status = stopped
 	p.info.Song = ""
 	p.info.Bitrate = 0
+	p.info.Stream = ""
+	p.info.Mirror = 0
+	p.info.Mirrors = 0
 	p.Info <- *p.info
 }
 
-func (p *Player) Load(url string) {
+func (p *Player) Load(url string) error {
 	if url == "" {
 		p.Stop()
-		return
+		return nil
 	}
-	log.Printf("loading %s\n", url)
-	cmd := fmt.Sprintf(`{"command": ["loadfile", "%s"]}%s`, url, "\n")
-	writeToMPV([]byte(cmd))
-	p.info.Url = url
+	if url != p.info.Url || len(p.streams) == 0 {
+		p.info.Url = url
+		p.streams = []string{url}
+		p.setStream(0)
+	}
+
+	return p.backend.Load(p.streams[p.stream])
 }
 
-func (p *Player) Quit() {
-	log.Println("quitting mpv")
-	cmd := fmt.Sprintf(`{"command": ["quit", 9]}%s`, "\n")
+// setStream makes the i-th of the station's streams the active one, it
+// expects the lock to be held.
+func (p *Player) setStream(i int) {
+	p.stream = i
+	p.failures = 0
+	p.info.Stream = p.streams[i]
+	p.info.Mirror = i + 1
+	p.info.Mirrors = len(p.streams)
+}
 
-	if ok := writeToMPV([]byte(cmd)); !ok && p.cmd != nil {
-		log.Println("mpv failed to quit via socket")
-		p.cmd.Process.Signal(os.Kill)
-		p.cmd.Wait()
+// StreamInfo tells more about the stream that is playing when the backend
+// can, ok is false otherwise.
+func (p *Player) StreamInfo() (info StreamInfo, ok bool) {
+	inspector, ok := p.backend.(streamInspector)
+	if !ok {
+		return StreamInfo{}, false
 	}
-}
 
-func (p *Player) readMPVEvents() {
-	c, err := netDial()
+	info, err := inspector.StreamInfo()
 	if err != nil {
 		log.Println(err)
-		return
+		return StreamInfo{}, false
 	}
-	defer c.Close()
 
-	cmds := []string{
-		fmt.Sprintf(`{"command": ["observe_property", 1, "filtered-metadata"]}%s`, "\n"),
-		fmt.Sprintf(`{"command": ["observe_property", 1, "audio-bitrate"]}%s`, "\n"),
-		fmt.Sprintf(`{"command": ["observe_property", 1, "pause"]}%s`, "\n"),
-	}
+	return info, true
+}
 
-	for _, cmd := range cmds {
-		if _, err = c.Write([]byte(cmd)); err != nil {
-			log.Println(err)
-		}
+func (p *Player) Quit() {
+	if err := p.backend.Quit(); err != nil {
+		log.Println(err)
 	}
+}
 
-	for {
-		eventBytes, err := bufio.NewReader(c).ReadBytes([]byte("\n")[0])
+func (p *Player) readEvents() {
+	for e := range p.backend.Events() {
+		p.Lock()
+		resolving := p.resolving
+		p.Unlock()
 
-		if err != nil {
-			log.Println(err)
+		if resolving {
+			log.Printf("dropping %+v of the previous station\n", e)
 			continue
 		}
 
-		rsp := unmarshal(eventBytes)
-
-		if eventIs(rsp, "property-change") && nameIs(rsp, "audio-bitrate") {
-			br, ok := rsp["data"].(float64)
-			if ok {
-				p.info.Bitrate = int(math.Round(br / 1000.0))
-				p.Info <- *p.info
-			}
-		} else {
-			log.Println(rsp)
+		switch e.Kind {
+		case EventBitrate:
+			p.Lock()
+			p.info.Bitrate = e.Bitrate
+			p.Info <- *p.info
+			p.Unlock()
+		case EventPlaying:
+			p.setStatusPlaying()
+		case EventRestarted:
+			p.setStatusRestarted()
+		case EventCodec:
+			p.Lock()
+			p.info.Codec = e.Codec
+			p.Unlock()
+		case EventMetadata:
+			p.setCurrentSong(e.Metadata)
 		}
 
-		// MPV pauses (at least on Mac) after switching off bluetooth headphones (resuming playback on main soundcard).
-		// Let's permanently disable pause, we don't need it.
-		if eventIs(rsp, "property-change") && nameIs(rsp, "pause") {
-			if pause, ok := rsp["data"].(bool); ok && pause {
-				cmd := fmt.Sprintf(`{"command": ["set_property", "pause", false]}%s`, "\n")
-				writeToMPV([]byte(cmd))
-			}
+		if isUnexpectedEnd(e) {
+			go p.retryLoad()
+			p.setStatusUnexpectedEndFile(e.Reason, e.Error)
 		}
+	}
+}
 
-		if eventIs(rsp, "playback-restart") && p.info.Status == buffering {
-			p.setStatusPlaying()
-		}
+func (p *Player) retryLoad() {
+	p.Lock()
+	retry := p.retry
+	delay := (1 << retry.count) * p.retryDelay
+	p.Unlock()
 
-		if eventIs(rsp, "property-change") && nameIs(rsp, "filtered-metadata") {
-			meta, ok := rsp["data"].(map[string]any)
-			if ok {
-				p.setCurrentSong(meta)
-			}
-		}
+	if retry.ctx == nil {
+		return
+	}
 
-		if eventIs(rsp, "end-file") && reasonIsAnyOf(rsp, "eof", "error", "unknown") {
-			go func() {
-				select {
-				case <-p.retry.ctx.Done():
-					log.Println("Retry loading is cancelled")
-					return
-				case <-time.After((1 << p.retry.count) * time.Second):
-					p.retry.count++
-					p.info.PrevSong = ""
-					p.info.Status = buffering
-					p.Info <- *p.info
-					p.Load(p.info.Url)
-				}
-			}()
-			p.setStatusUnexpectedEndFile(rsp["reason"].(string))
-		}
+	select {
+	case <-retry.ctx.Done():
+		log.Println("Retry loading is cancelled")
+		return
+	case <-time.After(delay):
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if retry.ctx.Err() != nil {
+		return
+	}
+
+	retry.count++
+	p.failures++
+	if p.failures >= failoverAfter && len(p.streams) > 1 {
+		p.setStream((p.stream + 1) % len(p.streams))
+		log.Printf("failing over to %s\n", p.info.Stream)
+	}
+	p.info.PrevSong = ""
+	p.info.Status = buffering
+	p.Info <- *p.info
+	if err := p.Load(p.info.Url); err != nil {
+		p.setStatusError(err)
 	}
 }
 
 func (p *Player) setStatusPlaying() {
 	p.Lock()
+	defer p.Unlock()
+
+	if p.info.Status != buffering && p.info.Status != restarted {
+		return
+	}
+
 	p.info.Status = playing
 	p.info.Song = ""
+	p.failures = 0
+	p.Info <- *p.info
+
+	if p.record {
+		if err := p.startRecording(); err != nil {
+			p.setStatusError(err)
+		}
+	}
+}
+
+func (p *Player) setStatusRestarted() {
+	p.Lock()
+	p.stopRecording()
+	p.info.Status = restarted
+	p.info.Song = ""
+	p.info.PrevSong = ""
+	p.info.Bitrate = 0
 	p.Info <- *p.info
 	p.Unlock()
 }
 
-func (p *Player) setStatusUnexpectedEndFile(reason string) {
+func (p *Player) setStatusUnexpectedEndFile(reason, fileError string) {
 	p.Lock()
-	p.info.Status = fmt.Sprintf("Network or stream issues: %s", reason)
+	p.stopRecording()
+	if fileError != "" {
+		p.info.Status = fmt.Sprintf("Network or stream issues: %s (%s)", reason, fileError)
+	} else {
+		p.info.Status = fmt.Sprintf("Network or stream issues: %s", reason)
+	}
 	p.info.Song = ""
 	p.Info <- *p.info
 	p.Unlock()
 }
 
+// setStatusError expects the lock to be held.
+func (p *Player) setStatusError(err error) {
+	log.Println(err)
+	p.info.Status = fmt.Sprintf("Error: %s", err)
+	p.info.Song = ""
+	p.Info <- *p.info
+}
+
 func (p *Player) setCurrentSong(m map[string]any) {
-	if p.info.Status == buffering {
-		p.setStatusPlaying()
-	}
+	p.setStatusPlaying()
 
 	title, ok := m["icy-title"]
 
@@ -398,58 +539,18 @@ func (p *Player) setCurrentSong(m map[string]any) {
 		p.info.Status = ""
 		p.info.Song = song
 		p.Info <- *p.info
-	}
-}
-
-func writeToMPV(data []byte) bool {
-	c, err := netDial()
-
-	if err != nil {
-		log.Println(err, string(data))
-		return false
-	}
-
-	defer c.Close()
-
-	if _, err = c.Write(data); err != nil {
-		log.Println(err, string(data))
-		return false
-	}
-
-	return true
-}
-
-func mpvIsListening() bool {
-	_, err := netDial()
-	return err == nil
-}
-
-func unmarshal(data []byte) map[string]any {
-	res := make(map[string]any)
-
-	if err := json.Unmarshal(data, &res); err != nil {
-		log.Println(err)
-	}
-
-	return res
-}
 
-func reasonIsAnyOf(m map[string]any, needle ...string) bool {
-	if m["reason"] == nil {
-		return false
-	}
-
-	return slices.Contains(needle, m["reason"].(string))
-}
-
-func eventIs(m map[string]any, needle string) bool {
-	return m["event"] != nil && m["event"].(string) == needle
-}
+		if p.history != nil {
+			p.history.add(HistoryEntry{
+				Time:    time.Now(),
+				Station: stripPlayCount(p.info.Station),
+				URL:     p.info.Url,
+				Song:    song,
+			})
+		}
 
-func nameIs(m map[string]any, needle ...string) bool {
-	if m["name"] == nil {
-		return false
+		if err := p.splitRecording(); err != nil {
+			p.setStatusError(err)
+		}
 	}
-
-	return slices.Contains(needle, m["name"].(string))
 }
@@ -0,0 +1,394 @@
+//go:build !windows
+
+package radio
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testStation = Station{title: "Test FM", url: "http://test.fm/stream"}
+
+// waitInfo consumes player updates until one satisfies the condition.
+func waitInfo(t *testing.T, p *Player, cond func(Info) bool) Info {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case inf := <-p.Info:
+			if cond(inf) {
+				return inf
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for player info")
+			return Info{}
+		}
+	}
+}
+
+func statusIs(status string) func(Info) bool {
+	return func(inf Info) bool {
+		return inf.Status == status
+	}
+}
+
+func TestToggle(t *testing.T) {
+	p, f := newTestPlayer(t)
+
+	go p.Toggle(testStation)
+
+	inf := waitInfo(t, p, statusIs(buffering))
+	if inf.Station != testStation.title {
+		t.Errorf("station = %q, want %q", inf.Station, testStation.title)
+	}
+	f.waitCommand("loadfile", testStation.url)
+
+	f.playbackRestart()
+	waitInfo(t, p, statusIs(playing))
+
+	go p.Toggle(testStation)
+
+	inf = waitInfo(t, p, statusIs(stopped))
+	if inf.Song != "" || inf.Bitrate != 0 {
+		t.Errorf("stopped player still reports song %q, bitrate %d", inf.Song, inf.Bitrate)
+	}
+	f.waitCommand("stop")
+}
+
+func TestToggleLoadError(t *testing.T) {
+	p, f := newTestPlayer(t)
+	f.fail("loadfile", "invalid parameter")
+
+	go p.Toggle(testStation)
+
+	waitInfo(t, p, statusIs("Error: mpv loadfile: invalid parameter"))
+}
+
+func TestRetryAfterEndFile(t *testing.T) {
+	p, f := newTestPlayer(t)
+
+	go p.Toggle(testStation)
+	waitInfo(t, p, statusIs(buffering))
+	f.waitCommand("loadfile", testStation.url)
+
+	f.endFile("error")
+	waitInfo(t, p, statusIs("Network or stream issues: error"))
+
+	waitInfo(t, p, statusIs(buffering))
+	f.waitCommand("loadfile", testStation.url)
+
+	f.playbackRestart()
+	waitInfo(t, p, statusIs(playing))
+}
+
+func TestRetryIsCancelledByStop(t *testing.T) {
+	p, f := newTestPlayer(t)
+
+	go p.Toggle(testStation)
+	waitInfo(t, p, statusIs(buffering))
+	f.waitCommand("loadfile", testStation.url)
+
+	f.endFile("eof")
+	waitInfo(t, p, statusIs("Network or stream issues: eof"))
+
+	go p.Toggle(testStation)
+	waitInfo(t, p, statusIs(stopped))
+
+	select {
+	case inf := <-p.Info:
+		t.Errorf("unexpected update after stop: %+v", inf)
+	case <-time.After(1500 * time.Millisecond):
+	}
+}
+
+func TestSetCurrentSong(t *testing.T) {
+	p, f := newTestPlayer(t)
+
+	go p.Toggle(testStation)
+	waitInfo(t, p, statusIs(buffering))
+	f.waitCommand("loadfile", testStation.url)
+
+	f.metadata(map[string]any{"icy-title": "Artist - Song"})
+	waitInfo(t, p, statusIs(playing))
+	inf := waitInfo(t, p, func(inf Info) bool { return inf.Song != "" })
+	if inf.Song != "Artist - Song" || inf.Status != "" {
+		t.Errorf("song = %q, status = %q", inf.Song, inf.Status)
+	}
+
+	f.metadata(map[string]any{"icy-title": "Artist - Song"})
+	f.metadata(map[string]any{"Artist": "Someone", "Title": "Else", "icy-title": "ignored"})
+
+	inf = waitInfo(t, p, func(inf Info) bool { return true })
+	if inf.Song != "Someone - Else" {
+		t.Errorf("song = %q, want %q (repeated title must not be reported)", inf.Song, "Someone - Else")
+	}
+}
+
+func TestBitrate(t *testing.T) {
+	p, f := newTestPlayer(t)
+
+	f.bitrate(128400)
+
+	inf := waitInfo(t, p, func(inf Info) bool { return inf.Bitrate != 0 })
+	if inf.Bitrate != 128 {
+		t.Errorf("bitrate = %d, want 128", inf.Bitrate)
+	}
+}
+
+func TestSetVolume(t *testing.T) {
+	p, f := newTestPlayer(t)
+
+	go p.SetVolume(150)
+	inf := waitInfo(t, p, func(Info) bool { return true })
+	if inf.Volume != 100 {
+		t.Errorf("volume = %d, want 100", inf.Volume)
+	}
+	f.waitCommand("set_property", "volume", 100.0)
+
+	f.fail("set_property", "property unavailable")
+
+	if err := p.SetVolume(20); err == nil {
+		t.Error("expected an error from a failed set_property")
+	}
+	if p.info.Volume != 100 {
+		t.Errorf("volume changed to %d after a failed set_property", p.info.Volume)
+	}
+}
+
+func TestRestartAfterMPVIsLost(t *testing.T) {
+	p, f := newTestPlayer(t)
+
+	go p.SetVolume(40)
+	waitInfo(t, p, func(Info) bool { return true })
+
+	go p.Toggle(testStation)
+	waitInfo(t, p, statusIs(buffering))
+	f.waitCommand("loadfile", testStation.url)
+	f.playbackRestart()
+	waitInfo(t, p, statusIs(playing))
+
+	f.dropConnections()
+
+	waitInfo(t, p, statusIs(restarted))
+	f.waitCommand("loadfile", testStation.url)
+
+	b := p.backend.(*mpvBackend)
+	b.Lock()
+	args := b.cmd.Args
+	b.Unlock()
+
+	if !slices.Contains(args, "--volume=40") {
+		t.Errorf("mpv was restarted with %v, want the volume restored", args)
+	}
+
+	f.playbackRestart()
+	waitInfo(t, p, statusIs(playing))
+}
+
+func TestRecording(t *testing.T) {
+	p, f := newTestPlayer(t)
+	dir := t.TempDir()
+	p.SetRecording(dir, false, false)
+
+	go p.Toggle(testStation)
+	waitInfo(t, p, statusIs(buffering))
+	f.propertyChange("audio-codec-name", "mp3")
+	f.playbackRestart()
+	waitInfo(t, p, statusIs(playing))
+
+	go p.ToggleRecording()
+
+	inf := waitInfo(t, p, func(inf Info) bool { return inf.Recording != nil })
+	cmd := f.waitCommand("set_property", "stream-record", inf.Recording.Path)
+	if path := cmd[2].(string); filepath.Dir(path) != dir || filepath.Ext(path) != ".mp3" {
+		t.Errorf("recording to %s, want an .mp3 file in %s", path, dir)
+	}
+
+	go p.ToggleRecording()
+
+	waitInfo(t, p, func(inf Info) bool { return inf.Recording == nil })
+	f.waitCommand("set_property", "stream-record", "")
+}
+
+func TestRecordingWaitsForPlayback(t *testing.T) {
+	p, f := newTestPlayer(t)
+	p.SetRecording(t.TempDir(), true, false)
+
+	go p.Toggle(testStation)
+	waitInfo(t, p, statusIs(buffering))
+
+	f.playbackRestart()
+	inf := waitInfo(t, p, func(inf Info) bool { return inf.Recording != nil })
+	if filepath.Ext(inf.Recording.Path) != ".mka" {
+		t.Errorf("recording to %s, want .mka for an unknown codec", inf.Recording.Path)
+	}
+
+	go p.Toggle(testStation)
+	inf = waitInfo(t, p, statusIs(stopped))
+	if inf.Recording != nil {
+		t.Error("recording continues after the station was stopped")
+	}
+	f.waitCommand("set_property", "stream-record", "")
+}
+
+func TestRecordingSplitsTracks(t *testing.T) {
+	p, f := newTestPlayer(t)
+	dir := t.TempDir()
+	p.SetRecording(dir, true, true)
+
+	go p.Toggle(testStation)
+	waitInfo(t, p, statusIs(buffering))
+	f.propertyChange("audio-codec-name", "mp3")
+	f.playbackRestart()
+
+	inf := waitInfo(t, p, func(inf Info) bool { return inf.Recording != nil })
+	f.waitCommand("set_property", "stream-record", inf.Recording.Path)
+
+	f.metadata(map[string]any{"icy-title": "Artist - Song"})
+
+	inf = waitInfo(t, p, func(inf Info) bool { return inf.Recording != nil && inf.Recording.Song != "" })
+	want := filepath.Join(dir, "Test FM", "Artist - Song.mp3")
+	if inf.Recording.Path != want {
+		t.Errorf("recording to %s, want %s", inf.Recording.Path, want)
+	}
+	f.waitCommand("set_property", "stream-record", "")
+	f.waitCommand("set_property", "stream-record", want)
+}
+
+func TestFailover(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "[playlist]\nFile1=http://one.fm/stream\nFile2=http://two.fm/stream\n")
+	}))
+	defer srv.Close()
+
+	p, f := newTestPlayer(t)
+	p.retryDelay = time.Millisecond
+	station := Station{
+		title: "Mirrors FM",
+		url:   srv.URL + "/listen.pls",
+		urls:  []string{srv.URL + "/listen.pls", "http://three.fm/stream"},
+	}
+
+	go p.Toggle(station)
+	waitInfo(t, p, statusIs(buffering))
+	f.waitCommand("loadfile", "http://one.fm/stream")
+
+	for _, want := range []string{"one", "two", "two", "three", "three", "one"} {
+		f.endFile("error")
+		inf := waitInfo(t, p, statusIs(buffering))
+		stream := fmt.Sprintf("http://%s.fm/stream", want)
+		f.waitCommand("loadfile", stream)
+
+		if inf.Stream != stream || inf.Mirrors != 3 {
+			t.Errorf("mirror %d/%d %s, want %s", inf.Mirror, inf.Mirrors, inf.Stream, stream)
+		}
+	}
+
+	if p.info.Url != station.url {
+		t.Errorf("url = %q, want the station url %q", p.info.Url, station.url)
+	}
+}
+
+func TestToggleDropsEventsWhileResolving(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	release := make(chan struct{})
+	var once sync.Once
+	resolve := func() { once.Do(func() { close(release) }) }
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		fmt.Fprint(w, "[playlist]\nFile1=http://new.fm/stream\n")
+	}))
+	defer srv.Close()
+	defer resolve()
+
+	p, f := newTestPlayer(t)
+	history := NewSongHistory()
+	p.SetHistory(history)
+
+	go p.Toggle(testStation)
+	waitInfo(t, p, statusIs(buffering))
+	f.waitCommand("loadfile", testStation.url)
+	f.playbackRestart()
+	waitInfo(t, p, statusIs(playing))
+
+	station := Station{title: "Playlist FM", url: srv.URL + "/listen.pls"}
+	go p.Toggle(station)
+	waitInfo(t, p, func(inf Info) bool { return inf.Station == station.title && inf.Status == buffering })
+	f.waitCommand("stop")
+
+	// the previous station is still talking
+	f.metadata(map[string]any{"icy-title": "Old - Song"})
+	f.endFile("error")
+
+	select {
+	case inf := <-p.Info:
+		t.Errorf("the previous station updated the new one: %+v", inf)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	resolve()
+	f.waitCommand("loadfile", "http://new.fm/stream")
+
+	if entries := history.recent(""); len(entries) != 0 {
+		t.Errorf("the song of the previous station was added to the history: %+v", entries)
+	}
+}
+
+func TestStreamInfo(t *testing.T) {
+	p, f := newTestPlayer(t)
+
+	f.Lock()
+	f.props["stream-open-filename"] = "http://a/stream"
+	f.props["audio-codec"] = "MP3 (MPEG audio layer 3)"
+	f.props["audio-params"] = map[string]any{"samplerate": 44100, "channel-count": 2, "hr-channels": "stereo"}
+	f.props["audio-bitrate"] = 128000
+	f.props["metadata"] = map[string]any{"icy-name": "A FM", "icy-genre": "Jazz", "icy-title": "", "title": "Song"}
+	f.props["demuxer-cache-state"] = map[string]any{"cache-duration": 2.5, "fw-bytes": 4096}
+	f.props["paused-for-cache"] = true
+	f.Unlock()
+
+	info, ok := p.StreamInfo()
+	if !ok {
+		t.Fatal("no stream info")
+	}
+
+	want := StreamInfo{
+		URL:        "http://a/stream",
+		Codec:      "MP3 (MPEG audio layer 3)",
+		SampleRate: 44100,
+		Channels:   2,
+		Layout:     "stereo",
+		Bitrate:    128,
+		ICY:        map[string]string{"icy-name": "A FM", "icy-genre": "Jazz"},
+		Buffered:   2500 * time.Millisecond,
+		BufferSize: 4096,
+		Buffering:  true,
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+
+	details := streamDetails(Info{Status: "Playing"}, info, ok)
+	for _, s := range []string{"44100 Hz", "2 (stereo)", "128 kb/s", "A FM", "2.5 s, 4 KiB"} {
+		if !strings.Contains(details, s) {
+			t.Errorf("%q is not in the details:\n%s", s, details)
+		}
+	}
+	if strings.Index(details, "icy-name") > strings.Index(details, "icy-genre") {
+		t.Errorf("icy-name should go first:\n%s", details)
+	}
+}
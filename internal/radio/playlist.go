@@ -0,0 +1,259 @@
+package radio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxPlaylistDepth = 3
+	maxPlaylistSize  = 1 << 20
+)
+
+var (
+	playlistClient = &http.Client{Timeout: 5 * time.Second}
+
+	playlistExtensions = map[string]string{
+		".pls":  "pls",
+		".m3u":  "m3u",
+		".m3u8": "m3u",
+		".asx":  "asx",
+		".xspf": "xspf",
+	}
+
+	playlistContentTypes = map[string]string{
+		"audio/x-scpls":                 "pls",
+		"audio/scpls":                   "pls",
+		"audio/x-mpegurl":               "m3u",
+		"audio/mpegurl":                 "m3u",
+		"application/x-mpegurl":         "m3u",
+		"application/vnd.apple.mpegurl": "m3u",
+		"video/x-ms-asx":                "asx",
+		"audio/x-ms-asx":                "asx",
+		"application/xspf+xml":          "xspf",
+	}
+
+	reASXRef = regexp.MustCompile(`(?is)<ref\b[^>]*?\bhref\s*=\s*["']([^"']+)["']`)
+	rePLSKey = regexp.MustCompile(`(?i)^file(\d+)$`)
+
+	errEmptyPlaylist = errors.New("playlist has no streams")
+)
+
+// resolveStreams returns the streams of a playlist in the order they should
+// be tried, or the URL itself when it isn't a playlist or can't be resolved.
+func resolveStreams(rawURL string) []string {
+	if playlistKind("", rawURL) == "" {
+		return []string{rawURL}
+	}
+
+	streams, err := resolvePlaylist(playlistClient, rawURL, 0)
+	if err != nil {
+		log.Println(err)
+		return []string{rawURL}
+	}
+
+	log.Printf("%s resolved to %v\n", rawURL, streams)
+	return streams
+}
+
+// resolvePlaylist fetches the URL and returns the streams it lists, nested
+// playlists are resolved as well. A URL serving a stream (or an HLS
+// playlist, which the backends play themselves) resolves to itself.
+func resolvePlaylist(client *http.Client, rawURL string, depth int) ([]string, error) {
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+
+	kind := playlistKind(resp.Header.Get("Content-Type"), rawURL)
+	if kind == "" {
+		return []string{rawURL}, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPlaylistSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if kind == "m3u" && bytes.Contains(body, []byte("#EXT-X-")) {
+		return []string{rawURL}, nil
+	}
+
+	entries, err := parsePlaylist(kind, body)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse %s: %w", rawURL, err)
+	}
+
+	var streams []string
+
+	for _, e := range entries {
+		u, err := resp.Request.URL.Parse(e)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+
+		stream := u.String()
+
+		if playlistKind("", stream) != "" && depth < maxPlaylistDepth {
+			nested, err := resolvePlaylist(client, stream, depth+1)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			streams = append(streams, nested...)
+			continue
+		}
+
+		streams = append(streams, stream)
+	}
+
+	streams = uniqueStrings(streams)
+	if len(streams) == 0 {
+		return nil, fmt.Errorf("%s: %w", rawURL, errEmptyPlaylist)
+	}
+
+	return streams, nil
+}
+
+// playlistKind tells the playlist format by the content type, or by the
+// extension when the content type is missing or generic.
+func playlistKind(contentType, rawURL string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if kind, ok := playlistContentTypes[strings.ToLower(mediaType)]; ok {
+			return kind
+		}
+		if strings.HasPrefix(mediaType, "audio/") || strings.HasPrefix(mediaType, "video/") {
+			return ""
+		}
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return playlistExtensions[strings.ToLower(path.Ext(u.Path))]
+}
+
+func parsePlaylist(kind string, body []byte) ([]string, error) {
+	switch kind {
+	case "pls":
+		return parsePLS(body), nil
+	case "m3u":
+		return parseM3U(body), nil
+	case "asx":
+		return parseASX(body), nil
+	case "xspf":
+		return parseXSPF(body)
+	}
+	return nil, fmt.Errorf("unknown playlist format %q", kind)
+}
+
+// parsePLS returns the FileN entries ordered by N.
+func parsePLS(body []byte) []string {
+	type entry struct {
+		n   int
+		url string
+	}
+
+	var entries []entry
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+
+		if m := rePLSKey.FindStringSubmatch(strings.TrimSpace(key)); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			entries = append(entries, entry{n, strings.TrimSpace(value)})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].n < entries[j].n
+	})
+
+	urls := make([]string, 0, len(entries))
+	for _, e := range entries {
+		urls = append(urls, e.url)
+	}
+
+	return urls
+}
+
+func parseM3U(body []byte) []string {
+	var urls []string
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))))
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			urls = append(urls, line)
+		}
+	}
+
+	return urls
+}
+
+// parseASX uses a regexp as ASX files are rarely valid XML and the element
+// names come in any case.
+func parseASX(body []byte) []string {
+	var urls []string
+	for _, m := range reASXRef.FindAllSubmatch(body, -1) {
+		urls = append(urls, strings.TrimSpace(html.UnescapeString(string(m[1]))))
+	}
+	return urls
+}
+
+func parseXSPF(body []byte) ([]string, error) {
+	var playlist struct {
+		Tracks []struct {
+			Locations []string `xml:"location"`
+		} `xml:"trackList>track"`
+	}
+
+	if err := xml.Unmarshal(body, &playlist); err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, t := range playlist.Tracks {
+		for _, l := range t.Locations {
+			urls = append(urls, strings.TrimSpace(l))
+		}
+	}
+
+	return urls, nil
+}
+
+func uniqueStrings(s []string) []string {
+	var unique []string
+	for _, v := range s {
+		if !slices.Contains(unique, v) {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,84 @@
+package radio
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestParsePlaylists(t *testing.T) {
+	tests := []struct {
+		kind string
+		body string
+		want []string
+	}{
+		{"pls", "[playlist]\nNumberOfEntries=2\nFile2=http://b/\nTitle1=A\nFile1=http://a/\n", []string{"http://a/", "http://b/"}},
+		{"m3u", "\xef\xbb\xbf#EXTM3U\n#EXTINF:-1,A\nhttp://a/\n\n  http://b/  \n", []string{"http://a/", "http://b/"}},
+		{"asx", `<ASX version="3.0"><Entry><REF HREF="http://a/?x=1&amp;y=2" /></Entry><entry><ref href='http://b/'/></entry></ASX>`, []string{"http://a/?x=1&y=2", "http://b/"}},
+		{"xspf", `<?xml version="1.0"?><playlist version="1" xmlns="http://xspf.org/ns/0/"><trackList><track><location>http://a/</location></track><track><location>http://b/</location></track></trackList></playlist>`, []string{"http://a/", "http://b/"}},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePlaylist(tt.kind, []byte(tt.body))
+		if err != nil {
+			t.Errorf("%s: %v", tt.kind, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestResolvePlaylist(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/radio.pls", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "[playlist]\nFile1=%s/nested.m3u\nFile2=/stream\nFile3=ftp://ignored/\n", srv.URL)
+	})
+	mux.HandleFunc("/nested.m3u", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\nmirror\n/stream\n")
+	})
+	mux.HandleFunc("/listen", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/x-mpegurl")
+		fmt.Fprint(w, "/stream\n")
+	})
+	mux.HandleFunc("/hls.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=128000\nlow.m3u8\n")
+	})
+	mux.HandleFunc("/empty.pls", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "[playlist]\nNumberOfEntries=0\n")
+	})
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+	})
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/radio.pls", []string{srv.URL + "/mirror", srv.URL + "/stream"}},
+		{"/listen", []string{srv.URL + "/stream"}},
+		{"/hls.m3u8", []string{srv.URL + "/hls.m3u8"}},
+		{"/stream", []string{srv.URL + "/stream"}},
+	}
+
+	for _, tt := range tests {
+		got, err := resolvePlaylist(srv.Client(), srv.URL+tt.path, 0)
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	for _, path := range []string{"/empty.pls", "/missing.pls"} {
+		if _, err := resolvePlaylist(srv.Client(), srv.URL+path, 0); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+	}
+}
@@ -1,73 +1,245 @@
 package radio
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"math/rand"
-	"net"
-	"net/http"
 	"net/url"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
-	"time"
 )
 
-const maxStationNameLen = 50
+const (
+	maxStationNameLen    = 50
+	radioBrowserPageSize = 50
+	maxCategories        = 500
+)
 
 var reCleanName = regexp.MustCompile(`[^a-zA-Z0-9.,'` + "`" + `"&/ ]`)
 
 type RadioBrowserResult struct {
-	station     Station
-	countryCode string
-	bitrate     int
+	station Station
 }
 
 type radioBrowserStation struct {
+	UUID        string `json:"stationuuid"`
 	Name        string `json:"name"`
 	URL         string `json:"url"`
 	URLResolved string `json:"url_resolved"`
 	CountryCode string `json:"countrycode"`
 	Tags        string `json:"tags"`
 	Bitrate     int    `json:"bitrate"`
+	Homepage    string `json:"homepage"`
+	Favicon     string `json:"favicon"`
+	Language    string `json:"language"`
+	Codec       string `json:"codec"`
 }
 
-func SearchRadioBrowser(query string) ([]RadioBrowserResult, error) {
-	server, err := radioBrowserServer()
-	if err != nil {
-		server = "de1.api.radio-browser.info"
-	}
+// RadioBrowserQuery holds the filters of a radio-browser search, the empty
+// ones are not applied.
+type RadioBrowserQuery struct {
+	Name       string
+	Tag        string
+	Country    string // a name or a two letter code
+	Language   string
+	Codec      string
+	MinBitrate int
+	Order      string // one of RadioBrowserOrders, by clicks when empty
+	Offset     int
+	title      string // names the query instead of the filters
+	list       string // one of radioBrowserLists instead of a search
+}
+
+// radioBrowserLists are the lists of stations ranked by radio-browser.info.
+var radioBrowserLists = []struct {
+	title string
+	list  string
+}{
+	{"Most played", "topclick"},
+	{"Most voted", "topvote"},
+	{"Played just now", "lastclick"},
+	{"Recently changed", "lastchange"},
+}
+
+type radioBrowserStatus struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+}
+
+type radioBrowserCategory struct {
+	Name         string `json:"name"`
+	CountryCode  string `json:"iso_3166_1"`
+	StationCount int    `json:"stationcount"`
+}
+
+// radioBrowserDirectory lists the categories of the stations, the query
+// finds the stations of a category.
+type radioBrowserDirectory struct {
+	title string
+	path  string
+	query func(c radioBrowserCategory) RadioBrowserQuery
+}
+
+var radioBrowserDirectories = []radioBrowserDirectory{
+	{"Countries", "/json/countries", func(c radioBrowserCategory) RadioBrowserQuery {
+		return RadioBrowserQuery{Country: c.CountryCode, title: c.Name}
+	}},
+	{"Languages", "/json/languages", func(c radioBrowserCategory) RadioBrowserQuery {
+		return RadioBrowserQuery{Language: c.Name, title: c.Name}
+	}},
+	{"Tags", "/json/tags", func(c radioBrowserCategory) RadioBrowserQuery {
+		return RadioBrowserQuery{Tag: c.Name, title: c.Name}
+	}},
+}
+
+// RadioBrowserOrders are the orders of the search results, the most relevant
+// first except for names.
+var RadioBrowserOrders = []string{"clickcount", "votes", "bitrate", "name", "random"}
 
+func (q RadioBrowserQuery) params() url.Values {
 	params := url.Values{}
-	params.Set("name", query)
+
+	if q.list != "" {
+		// the lists have their own order and take no filters
+		params.Set("hidebroken", "true")
+		params.Set("limit", strconv.Itoa(radioBrowserPageSize))
+		if q.Offset > 0 {
+			params.Set("offset", strconv.Itoa(q.Offset))
+		}
+		return params
+	}
+
+	set := func(key, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			params.Set(key, value)
+		}
+	}
+
+	set("name", q.Name)
+	set("tag", strings.ToLower(q.Tag))
+	if country := strings.TrimSpace(q.Country); len(country) == 2 {
+		set("countrycode", strings.ToUpper(country))
+	} else {
+		set("country", country)
+	}
+	set("language", strings.ToLower(q.Language))
+	set("codec", q.Codec)
+	if q.MinBitrate > 0 {
+		params.Set("bitrateMin", strconv.Itoa(q.MinBitrate))
+	}
+
+	order := q.Order
+	if order == "" {
+		order = "clickcount"
+	}
+	params.Set("order", order)
+	params.Set("reverse", strconv.FormatBool(order != "name"))
 	params.Set("hidebroken", "true")
-	params.Set("order", "clickcount")
-	params.Set("reverse", "true")
-	params.Set("limit", "50")
+	params.Set("limit", strconv.Itoa(radioBrowserPageSize))
+	if q.Offset > 0 {
+		params.Set("offset", strconv.Itoa(q.Offset))
+	}
 
-	apiURL := fmt.Sprintf("https://%s/json/stations/search?%s", server, params.Encode())
+	return params
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return nil, err
+// filters describes the filters other than the name.
+func (q RadioBrowserQuery) filters() string {
+	var filters []string
+	for _, f := range []string{q.Tag, q.Country, q.Language, q.Codec} {
+		if f = strings.TrimSpace(f); f != "" {
+			filters = append(filters, f)
+		}
 	}
-	req.Header.Set("User-Agent", "goradion/"+Version)
+	if q.MinBitrate > 0 {
+		filters = append(filters, fmt.Sprintf("%dk+", q.MinBitrate))
+	}
+	return strings.Join(filters, ", ")
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+// String names the list of the found stations.
+func (q RadioBrowserQuery) String() string {
+	if q.title != "" {
+		return q.title
+	}
+
+	name := strings.TrimSpace(q.Name)
+	filters := q.filters()
+
+	switch {
+	case name == "":
+		return filters
+	case filters == "":
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, filters)
+}
+
+// SearchRadioBrowser returns a page of the stations matching the query, or
+// of a ranked list when the query names one, and tells whether there are
+// more of them.
+func SearchRadioBrowser(q RadioBrowserQuery) ([]RadioBrowserResult, bool, error) {
+	path := "/json/stations/search"
+	if q.list != "" {
+		path = "/json/stations/" + q.list
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	var stations []radioBrowserStation
+	if err := radioBrowser.get(path, q.params(), &stations); err != nil {
+		return nil, false, err
 	}
 
-	var apiResults []radioBrowserStation
-	if err := json.NewDecoder(resp.Body).Decode(&apiResults); err != nil {
+	return radioBrowserResults(stations), len(stations) == radioBrowserPageSize, nil
+}
+
+// radioBrowserCategories returns the categories of the directory with the
+// most stations first.
+func radioBrowserCategories(dir radioBrowserDirectory) ([]radioBrowserCategory, error) {
+	params := url.Values{}
+	params.Set("order", "stationcount")
+	params.Set("reverse", "true")
+	params.Set("hidebroken", "true")
+	params.Set("limit", strconv.Itoa(maxCategories))
+
+	var categories []radioBrowserCategory
+	if err := radioBrowser.get(dir.path, params, &categories); err != nil {
 		return nil, err
 	}
 
+	return slices.DeleteFunc(categories, func(c radioBrowserCategory) bool {
+		return strings.TrimSpace(c.Name) == "" || c.StationCount == 0 ||
+			dir.path == "/json/countries" && c.CountryCode == ""
+	}), nil
+}
+
+// radioBrowserClick counts a play of the station, radio-browser.info ranks
+// the stations by the clicks.
+func radioBrowserClick(uuid string) error {
+	var status radioBrowserStatus
+	if err := radioBrowser.get("/json/url/"+url.PathEscape(uuid), nil, &status); err != nil {
+		return err
+	}
+	if !status.OK {
+		return errors.New(status.Message)
+	}
+	return nil
+}
+
+// radioBrowserVote votes for the station, a station can only be voted for
+// once in a while from the same address.
+func radioBrowserVote(uuid string) error {
+	var status radioBrowserStatus
+	if err := radioBrowser.get("/json/vote/"+url.PathEscape(uuid), nil, &status); err != nil {
+		return err
+	}
+	if !status.OK {
+		return errors.New(status.Message)
+	}
+	return nil
+}
+
+func radioBrowserResults(apiResults []radioBrowserStation) []RadioBrowserResult {
 	results := make([]RadioBrowserResult, 0, len(apiResults))
 	for _, r := range apiResults {
 		streamURL := r.URLResolved
@@ -94,34 +266,22 @@ func SearchRadioBrowser(query string) ([]RadioBrowserResult, error) {
 		}
 
 		results = append(results, RadioBrowserResult{
-			station:     Station{title: name, url: streamURL, tags: tags},
-			countryCode: r.CountryCode,
-			bitrate:     r.Bitrate,
+			station: Station{
+				title:    name,
+				url:      streamURL,
+				tags:     tags,
+				homepage: r.Homepage,
+				country:  r.CountryCode,
+				language: r.Language,
+				codec:    r.Codec,
+				bitrate:  r.Bitrate,
+				logo:     r.Favicon,
+				uuid:     r.UUID,
+			},
 		})
 	}
 
-	return results, nil
-}
-
-func radioBrowserServer() (string, error) {
-	addrs, err := net.LookupHost("all.api.radio-browser.info")
-	if err != nil {
-		return "", err
-	}
-	if len(addrs) == 0 {
-		return "", fmt.Errorf("no servers found")
-	}
-
-	addr := addrs[rand.Intn(len(addrs))]
-	names, err := net.LookupAddr(addr)
-	if err != nil {
-		return "", err
-	}
-
-	if len(names) > 0 {
-		return strings.TrimSuffix(names[0], "."), nil
-	}
-	return "", fmt.Errorf("no server name found")
+	return results
 }
 
 func cleanStationName(name string) string {
@@ -0,0 +1,283 @@
+package radio
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// newTestRadioBrowser serves a radio-browser.info stand-in with 60 Lithuanian
+// stations and points the client at it. The clicked station UUIDs are sent
+// to the channel.
+func newTestRadioBrowser(t *testing.T) <-chan string {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/json/countries", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"name":"Lithuania","iso_3166_1":"LT","stationcount":60},{"name":"","iso_3166_1":"","stationcount":3}]`)
+	})
+	mux.HandleFunc("/json/stations/search", func(w http.ResponseWriter, r *http.Request) {
+		var stations []map[string]any
+		if r.FormValue("countrycode") == "LT" {
+			offset, _ := strconv.Atoi(r.FormValue("offset"))
+			limit, _ := strconv.Atoi(r.FormValue("limit"))
+			for i := offset; i < min(offset+limit, 60); i++ {
+				stations = append(stations, map[string]any{
+					"stationuuid": fmt.Sprintf("uuid-%d", i),
+					"name":        fmt.Sprintf("LT Radio %d", i),
+					"url":         fmt.Sprintf("http://lt.radio/%d", i),
+					"countrycode": "LT",
+				})
+			}
+		}
+		switch {
+		case r.FormValue("language") == "english":
+			stations = append(stations, map[string]any{"name": "English Radio", "url": "http://english.radio/"})
+		case r.FormValue("tag") == "jazz":
+			stations = append(stations, map[string]any{"name": "Jazz Radio", "url": "http://jazz.radio/"})
+		}
+		json.NewEncoder(w).Encode(stations)
+	})
+	mux.HandleFunc("/json/languages", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"name":"english","stationcount":2}]`)
+	})
+	mux.HandleFunc("/json/tags", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"name":"jazz","stationcount":1}]`)
+	})
+	clicks := make(chan string, 10)
+	mux.HandleFunc("/json/stations/topclick", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"stationuuid":"top","name":"Top Radio","url":"http://top.radio/","bitrate":128}]`)
+	})
+	mux.HandleFunc("/json/url/", func(w http.ResponseWriter, r *http.Request) {
+		clicks <- path.Base(r.URL.Path)
+		fmt.Fprint(w, `{"ok":true}`)
+	})
+	mux.HandleFunc("/json/vote/", func(w http.ResponseWriter, r *http.Request) {
+		if path.Base(r.URL.Path) == "voted" {
+			fmt.Fprint(w, `{"ok":false,"message":"VoteError: too often"}`)
+			return
+		}
+		fmt.Fprint(w, `{"ok":true,"message":"voted for station successfully"}`)
+	})
+
+	SetRadioBrowserURL(srv.URL + "/")
+	t.Cleanup(func() {
+		SetRadioBrowserURL("")
+	})
+
+	return clicks
+}
+
+func TestRadioBrowserQuery(t *testing.T) {
+	tests := []struct {
+		q      RadioBrowserQuery
+		params string
+		name   string
+	}{
+		{RadioBrowserQuery{Name: "jazz"},
+			"hidebroken=true&limit=50&name=jazz&order=clickcount&reverse=true", "jazz"},
+		{RadioBrowserQuery{Tag: "Jazz", Country: "lt", Language: "English", Codec: "AAC", MinBitrate: 128, Order: "name", Offset: 50},
+			"bitrateMin=128&codec=AAC&countrycode=LT&hidebroken=true&language=english&limit=50&offset=50&order=name&reverse=false&tag=jazz",
+			"Jazz, lt, English, AAC, 128k+"},
+		{RadioBrowserQuery{Name: "fm", Country: "Lithuania", Order: "votes"},
+			"country=Lithuania&hidebroken=true&limit=50&name=fm&order=votes&reverse=true", "fm (Lithuania)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.q.params().Encode(); got != tt.params {
+			t.Errorf("got %s, want %s", got, tt.params)
+		}
+		if got := tt.q.String(); got != tt.name {
+			t.Errorf("got %q, want %q", got, tt.name)
+		}
+	}
+}
+
+func TestRadioBrowserFailover(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "goradion/"+Version {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		fmt.Fprint(w, `{"ok":true}`)
+	}))
+	defer up.Close()
+
+	c := &radioBrowserClient{servers: []string{down.URL, up.URL}}
+
+	var status radioBrowserStatus
+	if err := c.get("/json/vote/x", nil, &status); err != nil || !status.OK {
+		t.Fatalf("got %v, %+v", err, status)
+	}
+
+	if !slices.Equal(c.servers, []string{up.URL, down.URL}) {
+		t.Errorf("the server that is down wasn't moved back: %v", c.servers)
+	}
+}
+
+func TestRadioBrowserByLatency(t *testing.T) {
+	server := func(delay time.Duration, code int) string {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+			w.WriteHeader(code)
+			fmt.Fprint(w, `{}`)
+		}))
+		t.Cleanup(srv.Close)
+		return srv.URL
+	}
+
+	broken := server(0, http.StatusInternalServerError)
+	slow := server(100*time.Millisecond, http.StatusOK)
+	fast := server(0, http.StatusOK)
+
+	if got := byLatency([]string{broken, slow, fast}); !slices.Equal(got, []string{fast, slow, broken}) {
+		t.Errorf("got %v, want %v", got, []string{fast, slow, broken})
+	}
+}
+
+func TestTUIBrowseDirectories(t *testing.T) {
+	clicks := newTestRadioBrowser(t)
+	h := newTUIHarness(t, testStations)
+
+	h.key(tcell.KeyCtrlS)
+	h.waitForText("Most played")
+	h.key(tcell.KeyTab)
+	h.key(tcell.KeyEnter)
+	h.waitForText("Top Radio (128k)")
+
+	h.key(tcell.KeyBackspace2)
+	h.waitForText("Countries")
+	h.rune('e')
+	h.waitForText("Lithuania (60)")
+
+	h.key(tcell.KeyEnter)
+	h.waitForText("LT Radio 0 (LT)")
+
+	h.key(tcell.KeyEnd)
+	h.waitForText("More results...")
+	h.key(tcell.KeyEnter)
+	h.waitForText("LT Radio 50 (LT)")
+	h.key(tcell.KeyEnd)
+	h.waitForText("LT Radio 59 (LT)")
+	h.waitForNoText("More results...")
+
+	h.key(tcell.KeyBackspace2)
+	h.waitForText("Lithuania (60)")
+	h.key(tcell.KeyEnter)
+	h.waitForText("LT Radio 0 (LT)")
+
+	h.key(tcell.KeyEnter)
+	h.waitForPage(Main)
+	h.waitForText("LT Radio 0 | " + buffering)
+
+	select {
+	case uuid := <-clicks:
+		if uuid != "uuid-0" {
+			t.Errorf("clicked %s, want uuid-0", uuid)
+		}
+	case <-time.After(time.Second):
+		t.Error("the play wasn't reported")
+	}
+}
+
+func TestTUIBrowseCategories(t *testing.T) {
+	newTestRadioBrowser(t)
+	h := newTUIHarness(t, testStations)
+
+	h.key(tcell.KeyCtrlS)
+	h.waitForText("Languages")
+	h.key(tcell.KeyTab)
+	h.rune('f')
+	h.waitForText("english (2)")
+	h.waitForNoText("Countries")
+
+	h.key(tcell.KeyEnter)
+	h.waitForText("English Radio")
+
+	h.key(tcell.KeyBackspace2)
+	h.waitForText("english (2)")
+	h.key(tcell.KeyBackspace2)
+	h.waitForText("Countries")
+
+	h.rune('g')
+	h.waitForText("jazz (1)")
+	h.key(tcell.KeyEnter)
+	h.waitForText("Jazz Radio")
+
+	h.key(tcell.KeyBackspace2)
+	h.waitForText("jazz (1)")
+	h.key(tcell.KeyBackspace2)
+	h.waitForText("Tags")
+
+	// the directories are the top level
+	h.key(tcell.KeyBackspace2)
+	h.waitForText("Countries")
+	if h.page() != h.app.pageNames[Browse] {
+		t.Errorf("Backspace on the directories left the radio browser")
+	}
+}
+
+func TestTUIClickAndVote(t *testing.T) {
+	clicks := newTestRadioBrowser(t)
+	h := newTUIHarness(t, []Station{
+		{title: "Found FM", url: "http://found.fm/", uuid: "found"},
+		{title: "Mine FM", url: "http://mine.fm/"},
+		{title: "Voted FM", url: "http://voted.fm/", uuid: "voted"},
+	})
+
+	h.rune('~')
+	h.waitForPage(Main)
+
+	h.rune('a')
+	h.waitForText("Found FM | " + buffering)
+	select {
+	case uuid := <-clicks:
+		if uuid != "found" {
+			t.Errorf("clicked %s, want found", uuid)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the play wasn't reported")
+	}
+
+	h.key(tcell.KeyCtrlV)
+	h.waitForText("Voted for Found FM")
+
+	h.rune('b')
+	h.waitForText("Mine FM | " + buffering)
+	h.key(tcell.KeyCtrlV)
+	h.waitForText("Only radio-browser.info stations can be voted for")
+
+	h.rune('c')
+	h.waitForText("Voted FM | " + buffering)
+	h.key(tcell.KeyCtrlV)
+	h.waitForText("Can't vote for Voted FM: VoteError: too often")
+
+	select {
+	case uuid := <-clicks:
+		if uuid != "voted" {
+			t.Errorf("clicked %s, want voted", uuid)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the play wasn't reported")
+	}
+	select {
+	case uuid := <-clicks:
+		t.Errorf("clicked %s again", uuid)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
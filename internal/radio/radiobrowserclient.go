@@ -0,0 +1,217 @@
+package radio
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	radioBrowserTimeout      = 10 * time.Second
+	radioBrowserProbeTimeout = 3 * time.Second
+	radioBrowserHost         = "all.api.radio-browser.info"
+)
+
+// radioBrowserFallbacks are tried when the mirrors can't be discovered.
+var radioBrowserFallbacks = []string{
+	"https://de1.api.radio-browser.info",
+	"https://de2.api.radio-browser.info",
+	"https://fi1.api.radio-browser.info",
+}
+
+var radioBrowser = &radioBrowserClient{}
+
+// radioBrowserClient sends the requests to the radio-browser.info mirrors,
+// moving on to the next one when a mirror fails.
+type radioBrowserClient struct {
+	sync.Mutex
+	base    string   // a single server to use instead of the mirrors
+	servers []string // the fastest first, discovered on the first request
+}
+
+// SetRadioBrowserURL points the radio-browser.info requests at a single
+// server, e.g. a self-hosted instance.
+func SetRadioBrowserURL(base string) {
+	radioBrowser.Lock()
+	defer radioBrowser.Unlock()
+
+	radioBrowser.base = strings.TrimSuffix(base, "/")
+	radioBrowser.servers = nil
+}
+
+// get decodes the JSON response of the first server that answers.
+func (c *radioBrowserClient) get(path string, params url.Values, v any) error {
+	var err error
+
+	for _, server := range c.serverList() {
+		ctx, cancel := context.WithTimeout(context.Background(), radioBrowserTimeout)
+		err = getRadioBrowser(ctx, server, path, params, v)
+		cancel()
+
+		var status statusError
+		if err == nil || errors.As(err, &status) && status.code < 500 {
+			// other servers would refuse the request too
+			return err
+		}
+
+		log.Println(err)
+		c.demote(server)
+	}
+
+	if err == nil {
+		err = errors.New("no radio-browser.info servers found")
+	}
+	return err
+}
+
+func (c *radioBrowserClient) serverList() []string {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.servers == nil {
+		if c.base != "" {
+			c.servers = []string{c.base}
+		} else {
+			c.servers = byLatency(discoverRadioBrowser())
+			log.Printf("radio-browser.info servers: %v\n", c.servers)
+		}
+	}
+
+	return slices.Clone(c.servers)
+}
+
+// demote moves the server that failed to the end of the list.
+func (c *radioBrowserClient) demote(server string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if i := slices.Index(c.servers, server); i >= 0 {
+		c.servers = append(slices.Delete(c.servers, i, i+1), server)
+	}
+}
+
+// discoverRadioBrowser finds the mirrors by DNS and asks them about the
+// others, as not every mirror is in DNS all the time.
+func discoverRadioBrowser() []string {
+	var servers []string
+
+	addrs, err := net.LookupHost(radioBrowserHost)
+	if err != nil {
+		log.Println(err)
+	}
+
+	for _, addr := range addrs {
+		names, err := net.LookupAddr(addr)
+		if err != nil || len(names) == 0 {
+			continue
+		}
+		servers = append(servers, "https://"+strings.TrimSuffix(names[0], "."))
+	}
+
+	for _, server := range append(slices.Clone(servers), radioBrowserFallbacks...) {
+		var mirrors []struct {
+			Name string `json:"name"`
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), radioBrowserProbeTimeout)
+		err := getRadioBrowser(ctx, server, "/json/servers", nil, &mirrors)
+		cancel()
+
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		for _, m := range mirrors {
+			if m.Name != "" {
+				servers = append(servers, "https://"+m.Name)
+			}
+		}
+		break
+	}
+
+	return uniqueStrings(append(servers, radioBrowserFallbacks...))
+}
+
+// byLatency orders the servers by how fast they answer, the ones that don't
+// answer go last.
+func byLatency(servers []string) []string {
+	latency := make(map[string]time.Duration, len(servers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), radioBrowserProbeTimeout)
+			defer cancel()
+
+			var stats map[string]any
+			start := time.Now()
+			d := radioBrowserProbeTimeout + time.Second
+
+			if err := getRadioBrowser(ctx, server, "/json/stats", nil, &stats); err == nil {
+				d = time.Since(start)
+			}
+
+			mu.Lock()
+			latency[server] = d
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	sorted := slices.Clone(servers)
+	slices.SortStableFunc(sorted, func(a, b string) int {
+		return cmp.Compare(latency[a], latency[b])
+	})
+	return sorted
+}
+
+type statusError struct {
+	url  string
+	code int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("%s returned status %d", e.url, e.code)
+}
+
+func getRadioBrowser(ctx context.Context, server, path string, params url.Values, v any) error {
+	apiURL := server + path
+	if len(params) > 0 {
+		apiURL += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "goradion/"+Version)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return statusError{apiURL, resp.StatusCode}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", apiURL, err)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package radio
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Untitled recordings this short are dropped when a track recording replaces
+// them, they are what was recorded before the first song title arrived.
+const splitMinDuration = 5 * time.Second
+
+var (
+	reUnsafeFileName = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]+`)
+
+	errNothingToRecord = errors.New("nothing is playing")
+)
+
+// Recording file extensions by audio codec, the container is picked by the
+// backend from the extension. Anything else goes into Matroska.
+var recordingExtensions = map[string]string{
+	"mp3":    ".mp3",
+	"aac":    ".aac",
+	"vorbis": ".ogg",
+	"opus":   ".opus",
+	"flac":   ".flac",
+}
+
+type Recording struct {
+	Path    string
+	Started time.Time
+	Station string
+	Song    string
+}
+
+func (r Recording) Size() int64 {
+	fi, err := os.Stat(r.Path)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+func (r Recording) String() string {
+	elapsed := time.Since(r.Started).Round(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d %s",
+		int(elapsed.Hours()), int(elapsed.Minutes())%60, int(elapsed.Seconds())%60, formatSize(r.Size()))
+}
+
+// ToggleRecording starts recording the current station or stops the
+// recording in progress.
+func (p *Player) ToggleRecording() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.record {
+		p.record = false
+		p.stopRecording()
+		p.Info <- *p.info
+		return nil
+	}
+
+	if p.info.Url == "" {
+		return errNothingToRecord
+	}
+
+	p.record = true
+
+	if p.info.Status == buffering {
+		// started once the stream is playing
+		return nil
+	}
+
+	return p.startRecording()
+}
+
+// startRecording expects the lock to be held.
+func (p *Player) startRecording() error {
+	if p.info.Recording != nil {
+		return nil
+	}
+
+	station := stripPlayCount(p.info.Station)
+	song := ""
+
+	path := recordingPath(p.recordDir, station, p.info.Codec, time.Now())
+	if p.splitTracks && p.info.Song != "" {
+		song = p.info.Song
+		path = trackPath(p.recordDir, station, song, p.info.Codec)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	if err := p.backend.Record(path); err != nil {
+		return err
+	}
+
+	log.Printf("recording to %s\n", path)
+	p.info.Recording = &Recording{Path: path, Started: time.Now(), Station: station, Song: song}
+	p.Info <- *p.info
+
+	return nil
+}
+
+// stopRecording expects the lock to be held.
+func (p *Player) stopRecording() {
+	if p.info.Recording == nil {
+		return
+	}
+
+	if err := p.backend.Record(""); err != nil {
+		log.Println(err)
+	}
+
+	rec := *p.info.Recording
+	log.Printf("recorded %s\n", rec.Path)
+	p.info.Recording = nil
+
+	if rec.Song != "" {
+		go func() {
+			err := writeTags(rec.Path, newTrackTags(rec.Song, rec.Station))
+			if err != nil && !errors.Is(err, errNotTaggable) {
+				log.Println(err)
+			}
+		}()
+	}
+}
+
+// splitRecording continues the recording in a file named after the current
+// song, it expects the lock to be held.
+func (p *Player) splitRecording() error {
+	rec := p.info.Recording
+	if !p.splitTracks || rec == nil || rec.Song == p.info.Song {
+		return nil
+	}
+
+	p.stopRecording()
+
+	if rec.Song == "" && time.Since(rec.Started) < splitMinDuration {
+		if err := os.Remove(rec.Path); err != nil {
+			log.Println(err)
+		}
+	}
+
+	return p.startRecording()
+}
+
+func recordingPath(dir, station, codec string, t time.Time) string {
+	return filepath.Join(dir, fmt.Sprintf("%s %s%s", safeFileName(station), t.Format("2006-01-02 150405"), recordingExtension(codec)))
+}
+
+// trackPath names a track recording "Artist - Title" in a directory of the
+// station, numbering the tracks that were already recorded.
+func trackPath(dir, station, song, codec string) string {
+	ext := recordingExtension(codec)
+	dir = filepath.Join(dir, safeFileName(station))
+	name := safeFileName(song)
+	path := filepath.Join(dir, name+ext)
+
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return path
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", name, i, ext))
+	}
+}
+
+func recordingExtension(codec string) string {
+	if ext, ok := recordingExtensions[codec]; ok {
+		return ext
+	}
+	return ".mka"
+}
+
+func safeFileName(s string) string {
+	s = strings.TrimSpace(reUnsafeFileName.ReplaceAllString(s, " "))
+	s = strings.Join(strings.Fields(s), " ")
+	if s == "" {
+		s = "goradion"
+	}
+	return s
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	default:
+		return fmt.Sprintf("%d kB", n/(1<<10))
+	}
+}
@@ -0,0 +1,129 @@
+package radio
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+	"time"
+)
+
+const stationsPollInterval = 2 * time.Second
+
+// SetSources tells where the stations come from, so that they are reloaded
+// when a local file of the sources changes and edited stations are saved.
+func (a *Application) SetSources(sources []string) {
+	if len(sources) == 0 {
+		sources = defaultSources()
+	}
+	a.sources = sources
+
+	if files := localSources(sources); len(files) > 0 {
+		go a.watchStations(sources, files, stationsPollInterval)
+	}
+}
+
+func localSources(sources []string) []string {
+	var files []string
+	for _, source := range sources {
+		if source != DefaultSource && !strings.HasPrefix(source, "http") {
+			files = append(files, source)
+		}
+	}
+	return files
+}
+
+// watchStations polls the files, as editors often replace a file instead of
+// writing to it. A change is picked up once the files stop changing.
+func (a *Application) watchStations(sources, files []string, interval time.Duration) {
+	last := fileStates(files)
+	changed := false
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		states := fileStates(files)
+
+		if !maps.Equal(states, last) {
+			last = states
+			changed = true
+			continue
+		}
+
+		if !changed {
+			continue
+		}
+		changed = false
+
+		stations, notes, err := mergeStations(sources)
+
+		a.app.QueueUpdateDraw(func() {
+			if err != nil {
+				log.Println(err)
+				a.status.SetText(fmt.Sprintf("[red]Can't reload stations: %s", stripBraces(err.Error())))
+				return
+			}
+
+			a.reloadStations(stations)
+			a.status.SetText(fmt.Sprintf("[yellow]Reloaded %d stations", len(stations)))
+			a.ShowNotes(notes)
+		})
+	}
+}
+
+func fileStates(files []string) map[string]string {
+	states := make(map[string]string, len(files))
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			states[f] = fmt.Sprintf("%s %d", fi.ModTime(), fi.Size())
+		}
+	}
+	return states
+}
+
+// reloadStations replaces the stations keeping the selected tag and station,
+// it must be called on the UI goroutine.
+func (a *Application) reloadStations(stations []Station) {
+	a.stations = stations
+	a.favorites.setStations(stations)
+
+	tag, _ := a.tagsList.GetItemText(a.tagsList.GetCurrentItem())
+	tagsFocused := a.app.GetFocus() == a.tagsList
+
+	a.refreshTagsPage()
+
+	for i := range a.tagsList.GetItemCount() {
+		if text, _ := a.tagsList.GetItemText(i); text == tag {
+			a.tagsList.SetCurrentItem(i)
+			break
+		}
+	}
+	if tagsFocused {
+		a.app.SetFocus(a.tagsList)
+	}
+
+	selected := ""
+	if i := a.stationsList.GetCurrentItem() - a.calculateStationListOffset(); i >= 0 && i < len(a.listedStations) {
+		selected = a.listedStations[i].url
+	}
+
+	switch {
+	case a.tag == a.lastSearchTag && a.lastBrowseStations != nil:
+		// online search results don't come from the stations files
+		return
+	case a.tag == a.lastSearchTag && a.lastSearchTag != "":
+		a.setupStationsList(a.stationsList, a.filterStations(a.tag))
+	case a.tag == "":
+		a.setupStationsList(a.stationsList, a.stations)
+	default:
+		a.filterStationsForSelectedTag()
+	}
+
+	for i, s := range a.listedStations {
+		if s.url == selected {
+			a.stationsList.SetCurrentItem(i + a.calculateStationListOffset())
+			break
+		}
+	}
+}
@@ -10,6 +10,6 @@ import (
 
 var socket = fmt.Sprintf("/tmp/mpv%d.sock", os.Getpid())
 
-func netDial() (net.Conn, error) {
+func netDial(socket string) (net.Conn, error) {
 	return net.Dial("unix", socket)
 }
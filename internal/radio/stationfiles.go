@@ -0,0 +1,189 @@
+package radio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	stationsExtensions = map[string]string{
+		".csv":  "csv",
+		".json": "json",
+		".toml": "toml",
+		".yaml": "yaml",
+		".yml":  "yaml",
+	}
+
+	stationsContentTypes = map[string]string{
+		"text/csv":           "csv",
+		"application/json":   "json",
+		"text/json":          "json",
+		"application/toml":   "toml",
+		"text/toml":          "toml",
+		"application/yaml":   "yaml",
+		"application/x-yaml": "yaml",
+		"text/yaml":          "yaml",
+		"text/x-yaml":        "yaml",
+	}
+)
+
+// stationsFile is the layout of the structured station files, stations can
+// be listed on their own or in groups, which may be nested. A group adds its
+// tag to the stations in it, nested groups add "Parent/Child" tags as well.
+type stationsFile struct {
+	Stations []stationEntry `json:"stations" toml:"stations" yaml:"stations"`
+	Groups   []stationGroup `json:"groups" toml:"groups" yaml:"groups"`
+}
+
+type stationGroup struct {
+	Tag      string         `json:"tag" toml:"tag" yaml:"tag"`
+	Stations []stationEntry `json:"stations" toml:"stations" yaml:"stations"`
+	Groups   []stationGroup `json:"groups" toml:"groups" yaml:"groups"`
+}
+
+type stationEntry struct {
+	Title       string   `json:"title" toml:"title" yaml:"title"`
+	URL         string   `json:"url" toml:"url" yaml:"url"`
+	URLs        []string `json:"urls" toml:"urls" yaml:"urls"`
+	Tags        []string `json:"tags" toml:"tags" yaml:"tags"`
+	Homepage    string   `json:"homepage" toml:"homepage" yaml:"homepage"`
+	Country     string   `json:"country" toml:"country" yaml:"country"`
+	Language    string   `json:"language" toml:"language" yaml:"language"`
+	Codec       string   `json:"codec" toml:"codec" yaml:"codec"`
+	Bitrate     int      `json:"bitrate" toml:"bitrate" yaml:"bitrate"`
+	Logo        string   `json:"logo" toml:"logo" yaml:"logo"`
+	Description string   `json:"description" toml:"description" yaml:"description"`
+	Volume      int      `json:"volume" toml:"volume" yaml:"volume"`
+	UUID        string   `json:"uuid" toml:"uuid" yaml:"uuid"`
+}
+
+// stationsFormat tells the format of a stations file by the content type,
+// or by the extension, CSV being the default.
+func stationsFormat(contentType, source string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if format, ok := stationsContentTypes[strings.ToLower(mediaType)]; ok {
+			return format
+		}
+	}
+
+	p := source
+	if u, err := url.Parse(source); err == nil && u.Scheme != "" && u.Host != "" {
+		p = u.Path
+	}
+
+	if format, ok := stationsExtensions[strings.ToLower(path.Ext(p))]; ok {
+		return format
+	}
+
+	return "csv"
+}
+
+func decodeStations(data []byte, format string) ([]Station, error) {
+	if format == "csv" {
+		return decodeStationsCSV(data)
+	}
+
+	var file stationsFile
+	var err error
+
+	switch format {
+	case "json":
+		// a plain list of stations is fine too
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+			err = json.Unmarshal(data, &file.Stations)
+		} else {
+			err = json.Unmarshal(data, &file)
+		}
+	case "toml":
+		err = toml.Unmarshal(data, &file)
+	case "yaml":
+		var node yaml.Node
+		if err = yaml.Unmarshal(data, &node); err == nil && len(node.Content) > 0 && node.Content[0].Kind == yaml.SequenceNode {
+			err = node.Decode(&file.Stations)
+		} else if err == nil {
+			err = node.Decode(&file)
+		}
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	stations := make([]Station, 0)
+	stations = appendStations(stations, file.Stations, nil)
+	for _, g := range file.Groups {
+		stations = appendGroup(stations, g, nil)
+	}
+
+	return stations, nil
+}
+
+func appendGroup(stations []Station, g stationGroup, parents []string) []Station {
+	tags := parents
+	if g.Tag != "" {
+		tag := g.Tag
+		if len(parents) > 0 {
+			tag = parents[len(parents)-1] + "/" + g.Tag
+		}
+		tags = append(append([]string{}, parents...), tag)
+	}
+
+	stations = appendStations(stations, g.Stations, tags)
+	for _, child := range g.Groups {
+		stations = appendGroup(stations, child, tags)
+	}
+
+	return stations
+}
+
+func appendStations(stations []Station, entries []stationEntry, groupTags []string) []Station {
+	for _, e := range entries {
+		var urls []string
+		for _, u := range append([]string{e.URL}, e.URLs...) {
+			if u = strings.TrimSpace(u); u != "" && !slices.Contains(urls, u) {
+				urls = append(urls, u)
+			}
+		}
+
+		if len(urls) == 0 {
+			log.Printf("Skipping station %q without a URL", e.Title)
+			continue
+		}
+
+		var tags []string
+		for _, t := range append(append([]string{}, groupTags...), e.Tags...) {
+			if t = strings.TrimSpace(t); t != "" && !slices.Contains(tags, t) {
+				tags = append(tags, t)
+			}
+		}
+
+		stations = append(stations, Station{
+			title:        strings.TrimSpace(e.Title),
+			url:          urls[0],
+			urls:         urls,
+			tags:         tags,
+			homepage:     e.Homepage,
+			country:      e.Country,
+			language:     e.Language,
+			codec:        e.Codec,
+			bitrate:      e.Bitrate,
+			logo:         e.Logo,
+			description:  e.Description,
+			volumeOffset: e.Volume,
+			uuid:         e.UUID,
+		})
+	}
+
+	return stations
+}
@@ -1,15 +1,18 @@
 package radio
 
 import (
+	"bytes"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
-	"sync"
-	"time"
 )
 
 const defaultStationsCSV = `FluxFM: Jazzradio Schwarzenstein,https://streams.fluxfm.de/jazzschwarz/mp3-320/audio/,Jazz;Instrumental
@@ -150,120 +153,316 @@ const defaultStationsCSV = `FluxFM: Jazzradio Schwarzenstein,https://streams.flu
 	NTS Mixtape: Otaku,https://stream-mixtape-geo.ntslive.net/mixtape36,Soundtrack;Mix`
 
 type Station struct {
-	title string
-	url   string
-	tags  []string
+	title        string
+	url          string
+	urls         []string
+	tags         []string
+	homepage     string
+	country      string
+	language     string
+	codec        string
+	bitrate      int
+	logo         string
+	description  string
+	volumeOffset int
+	uuid         string // of the station on radio-browser.info
 }
 
-func Stations(sta string) []Station {
-	var reader *csv.Reader
+// streams returns the URLs of the station in the order they are tried, the
+// first one (url) identifies the station.
+func (s Station) streams() []string {
+	if len(s.urls) > 0 {
+		return s.urls
+	}
+	return []string{s.url}
+}
+
+// DefaultSource names the built-in stations among the station sources.
+const DefaultSource = "default"
+
+var errCantFetch = errors.New("can't fetch stations")
+
+// Stations loads and merges the stations of the sources, which are local
+// files, links or DefaultSource. A station listed by several sources is
+// kept once, by its URL, with the tags of all of them. With more than one
+// source the stations are tagged with the name of their source. The notes
+// tell about the links that couldn't be fetched.
+func Stations(sources ...string) ([]Station, []string) {
+	stations, notes, err := mergeStations(sources)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	return stations, notes
+}
+
+// mergeStations returns an error when a local file can't be read or a file
+// can't be parsed.
+func mergeStations(sources []string) ([]Station, []string, error) {
+	if len(sources) == 0 {
+		sources = defaultSources()
+	}
+
+	stations := make([]Station, 0)
+	byURL := make(map[string]int)
+	var notes []string
+	fetchFailed := false
+
+	for _, source := range sources {
+		loaded, note, err := loadStations(source)
+		if err != nil && !errors.Is(err, errCantFetch) {
+			return nil, nil, err
+		}
+		if err != nil {
+			// a list that is not available can't be helped, unlike a local one
+			log.Println(err)
+			notes = append(notes, fmt.Sprintf("Can't fetch %s", source))
+			fetchFailed = true
+			continue
+		}
+		if note != "" {
+			notes = append(notes, note)
+		}
+
+		for _, st := range loaded {
+			st.tags = slices.Clone(st.tags)
+			if len(sources) > 1 {
+				st.tags = appendTags(st.tags, sourceTag(source))
+			}
+
+			if i, ok := byURL[st.url]; ok {
+				stations[i].tags = appendTags(stations[i].tags, st.tags...)
+				continue
+			}
 
-	if sta == "" {
-		reader = csv.NewReader(strings.NewReader(defaultStationsCSV))
-	} else if strings.HasPrefix(sta, "http") {
-		s, err := fetchStations(sta)
+			byURL[st.url] = len(stations)
+			stations = append(stations, st)
+		}
+	}
+
+	if len(stations) == 0 && fetchFailed {
+		stations, _, _ = loadStations(DefaultSource)
+		notes = append(notes, "Using the built-in stations")
+	}
+
+	return stations, notes, nil
+}
+
+// defaultSources uses the user stations file, written by the station
+// editor, in place of the built-in stations when it exists.
+func defaultSources() []string {
+	if _, err := os.Stat(userStationsFile()); err == nil {
+		return []string{userStationsFile()}
+	}
+	return []string{DefaultSource}
+}
+
+func userStationsFile() string {
+	return getConfigFile("stations.csv")
+}
+
+// loadStations returns an errCantFetch error when a link can't be fetched.
+// The note tells when a cached copy of a link is used.
+func loadStations(source string) ([]Station, string, error) {
+	data := []byte(defaultStationsCSV)
+	format := "csv"
+	note := ""
+
+	if strings.HasPrefix(source, "http") {
+		s, contentType, cacheNote, err := fetchStations(source)
 		if err != nil {
-			s = defaultStationsCSV
+			return nil, "", fmt.Errorf("%w: %w", errCantFetch, err)
 		}
-		reader = csv.NewReader(strings.NewReader(s))
-	} else {
-		file, err := os.Open(sta)
+		note = cacheNote
+		data = s
+		format = stationsFormat(contentType, source)
+	} else if source != DefaultSource {
+		s, err := os.ReadFile(source)
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			return nil, "", err
+		}
+		data = s
+		format = stationsFormat("", source)
+	}
+
+	stations, err := decodeStations(data, format)
+	if err != nil {
+		return nil, "", fmt.Errorf("Can't parse stations %s from %s: %w", strings.ToUpper(format), source, err)
+	}
+
+	return stations, note, nil
+}
+
+// sourceTag names a source by the host of a link or the name of a file.
+func sourceTag(source string) string {
+	if source == DefaultSource {
+		return "Default"
+	}
+
+	if u, err := url.Parse(source); err == nil && strings.HasPrefix(u.Scheme, "http") {
+		return u.Host
+	}
+
+	return strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+}
+
+func appendTags(tags []string, more ...string) []string {
+	for _, t := range more {
+		if !slices.Contains(tags, t) {
+			tags = append(tags, t)
 		}
-		defer file.Close()
-		reader = csv.NewReader(file)
 	}
+	return tags
+}
 
+func decodeStationsCSV(data []byte) ([]Station, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
 	reader.FieldsPerRecord = -1
-	records, err := reader.ReadAll()
 
+	records, err := reader.ReadAll()
 	if err != nil {
-		fmt.Println("Can't parse stations CSV:", err)
-		os.Exit(1)
+		return nil, err
+	}
+
+	return parseStations(records), nil
+}
+
+// parseStations reads the title, url and tags columns, or the columns named
+// in a header row when the file starts with one.
+func parseStations(records [][]string) []Station {
+	columns := map[string]int{"title": 0, "url": 1, "tags": 2}
+
+	if len(records) > 0 && isStationsHeader(records[0]) {
+		columns = make(map[string]int)
+		for i, name := range records[0] {
+			columns[columnName(name)] = i
+		}
+		records = records[1:]
+	}
+
+	field := func(r []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(r) {
+			return ""
+		}
+		return strings.Trim(r[i], " 	")
+	}
+
+	number := func(r []string, name string) int {
+		v := field(r, name)
+		if v == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			log.Printf("Invalid %s %q: %v", name, v, err)
+		}
+		return n
 	}
 
 	stations := make([]Station, 0)
 	for _, r := range records {
 		s := new(Station)
-		s.title = strings.Trim(r[0], " 	")
-		s.url = strings.Trim(r[1], " 	")
-		if len(r) > 2 {
-			s.tags = strings.Split(strings.Trim(r[2], " 	"), ";")
+		s.title = field(r, "title")
+		// mirrors of the stream are separated with a |
+		for _, u := range strings.Split(field(r, "url"), "|") {
+			if u = strings.Trim(u, " 	"); u != "" {
+				s.urls = append(s.urls, u)
+			}
 		}
+		if len(s.urls) == 0 {
+			continue
+		}
+		s.url = s.urls[0]
+		if tags := field(r, "tags"); tags != "" {
+			s.tags = strings.Split(tags, ";")
+		}
+		s.homepage = field(r, "homepage")
+		s.country = field(r, "country")
+		s.language = field(r, "language")
+		s.codec = field(r, "codec")
+		s.bitrate = number(r, "bitrate")
+		s.logo = field(r, "logo")
+		s.description = field(r, "description")
+		s.volumeOffset = number(r, "volume")
+		s.uuid = field(r, "uuid")
 		stations = append(stations, *s)
 	}
 
 	return stations
 }
 
-func CheckStations(stations []Station) {
-	var wg sync.WaitGroup
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	type result struct {
-		title string
-		url   string
-		ok    bool
-		err   string
+func isStationsHeader(r []string) bool {
+	names := make([]string, len(r))
+	for i, name := range r {
+		names[i] = columnName(name)
 	}
+	return slices.Contains(names, "title") && slices.Contains(names, "url")
+}
 
-	results := make([]result, len(stations))
+func columnName(name string) string {
+	name = strings.ToLower(strings.Trim(name, " 	"))
+	name = strings.NewReplacer(" ", "", "_", "", "-", "").Replace(name)
 
-	for i, s := range stations {
-		wg.Add(1)
-		go func(i int, s Station) {
-			defer wg.Done()
-			r := result{title: s.title, url: s.url}
-			resp, err := client.Get(s.url)
-			if err == nil {
-				resp.Body.Close()
-				r.ok = resp.StatusCode >= 200 && resp.StatusCode < 400
-			}
-			if !r.ok {
-				r.ok = checkMpv(s.url)
-			}
-			if !r.ok {
-				r.err = "stream not responding"
-			}
-			results[i] = r
-		}(i, s)
+	switch name {
+	case "name":
+		return "title"
+	case "volumeoffset":
+		return "volume"
+	case "favicon":
+		return "logo"
+	case "stationuuid":
+		return "uuid"
 	}
 
-	wg.Wait()
-
-	dead := 0
-	for _, r := range results {
-		if !r.ok {
-			fmt.Printf("  DEAD  %s  (%s)\n", r.title, r.err)
-			dead++
-		}
-	}
-	fmt.Printf("\n%d/%d stations alive, %d dead\n", len(stations)-dead, len(stations), dead)
+	return name
 }
 
-func checkMpv(rawURL string) bool {
-	cmd := exec.Command("mpv", "--no-video", "--no-terminal", "--frames=1", rawURL)
-	return cmd.Run() == nil
-}
-
-func fetchStations(url string) (string, error) {
-	resp, err := http.Get(url)
+// fetchStations fetches a list of stations, revalidating the cached copy of
+// it. The cached copy is used when the list can't be fetched, the note tells
+// about it.
+func fetchStations(url string) (data []byte, contentType, note string, err error) {
+	cache, cached := readStationsCache(url)
 
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return "", err
+		return nil, "", "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("Failed to GET %s, status code: %d", url, resp.StatusCode)
+	if cached {
+		if cache.ETag != "" {
+			req.Header.Set("If-None-Match", cache.ETag)
+		}
+		if cache.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cache.LastModified)
+		}
 	}
 
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	resp, err := stationsClient.Do(req)
+
+	if err == nil {
+		defer resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusNotModified && cached:
+			return cache.data, cache.ContentType, "", nil
+		case resp.StatusCode == http.StatusOK:
+			data, err = io.ReadAll(resp.Body)
+			if err == nil {
+				writeStationsCache(url, resp, data)
+				return data, resp.Header.Get("Content-Type"), "", nil
+			}
+		default:
+			err = fmt.Errorf("Failed to GET %s, status code: %d", url, resp.StatusCode)
+		}
+	}
+
+	if cached {
+		log.Println(err)
+		note = fmt.Sprintf("Can't fetch %s, using the copy from %s", url, cache.Fetched.Format("2006-01-02 15:04"))
+		return cache.data, cache.ContentType, note, nil
 	}
 
-	return string(data), nil
+	return nil, "", "", err
 }
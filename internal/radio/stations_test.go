@@ -0,0 +1,306 @@
+package radio
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func readTestStations(t *testing.T, s string) []Station {
+	t.Helper()
+
+	reader := csv.NewReader(strings.NewReader(s))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return parseStations(records)
+}
+
+func TestParseStations(t *testing.T) {
+	stations := readTestStations(t, "One,http://one/a | http://one/b,Jazz;Lounge\nTwo,http://two/\nNo URL\n")
+
+	if len(stations) != 2 {
+		t.Fatalf("got %d stations, want 2", len(stations))
+	}
+
+	one := stations[0]
+	if one.title != "One" || one.url != "http://one/a" || !slices.Equal(one.urls, []string{"http://one/a", "http://one/b"}) {
+		t.Errorf("one = %+v", one)
+	}
+	if !slices.Equal(one.tags, []string{"Jazz", "Lounge"}) {
+		t.Errorf("tags = %q", one.tags)
+	}
+	if stations[1].tags != nil {
+		t.Errorf("tags = %q, want none", stations[1].tags)
+	}
+}
+
+func TestParseStationsWithHeader(t *testing.T) {
+	stations := readTestStations(t, "URL,Title,Country,Bitrate,Volume Offset,Tags,Homepage,Unknown,StationUUID\n"+
+		"http://one/,One,NL,128,-10,Jazz,http://one.nl,x,9617a958\n"+
+		"http://two/,Two\n")
+
+	if len(stations) != 2 {
+		t.Fatalf("got %d stations, want 2", len(stations))
+	}
+
+	one := stations[0]
+	if one.title != "One" || one.url != "http://one/" || one.country != "NL" || one.bitrate != 128 ||
+		one.volumeOffset != -10 || one.homepage != "http://one.nl" || !slices.Equal(one.tags, []string{"Jazz"}) ||
+		one.uuid != "9617a958" {
+		t.Errorf("one = %+v", one)
+	}
+
+	if two := stations[1]; two.title != "Two" || two.url != "http://two/" {
+		t.Errorf("two = %+v", two)
+	}
+}
+
+func TestStationsFormat(t *testing.T) {
+	tests := []struct {
+		contentType, source, want string
+	}{
+		{"", "/path/stations.csv", "csv"},
+		{"", "stations.YML", "yaml"},
+		{"", "https://example.com/stations.toml?raw=1", "toml"},
+		{"application/json; charset=utf-8", "https://example.com/list", "json"},
+		{"text/plain", "https://example.com/stations.yaml", "yaml"},
+		{"", "https://example.com/list", "csv"},
+	}
+
+	for _, tt := range tests {
+		if got := stationsFormat(tt.contentType, tt.source); got != tt.want {
+			t.Errorf("stationsFormat(%q, %q) = %s, want %s", tt.contentType, tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeStations(t *testing.T) {
+	files := map[string]string{
+		"json": `{
+			"stations": [{"title": "One", "url": "http://one/", "urls": ["http://one/b"], "tags": ["Pop"], "bitrate": 128}],
+			"groups": [{"tag": "Jazz", "stations": [{"title": "Two", "url": "http://two/"}],
+				"groups": [{"tag": "Smooth", "stations": [{"title": "Three", "url": "http://three/", "volume": -5}]}]}]
+		}`,
+		"toml": `
+[[stations]]
+title = "One"
+url = "http://one/"
+urls = ["http://one/b"]
+tags = ["Pop"]
+bitrate = 128
+
+[[groups]]
+tag = "Jazz"
+
+[[groups.stations]]
+title = "Two"
+url = "http://two/"
+
+[[groups.groups]]
+tag = "Smooth"
+
+[[groups.groups.stations]]
+title = "Three"
+url = "http://three/"
+volume = -5
+`,
+		"yaml": `
+stations:
+  - title: One
+    url: http://one/
+    urls: [http://one/b]
+    tags: [Pop]
+    bitrate: 128
+groups:
+  - tag: Jazz
+    stations:
+      - {title: Two, url: http://two/}
+    groups:
+      - tag: Smooth
+        stations:
+          - {title: Three, url: http://three/, volume: -5}
+`,
+	}
+
+	for format, data := range files {
+		stations, err := decodeStations([]byte(data), format)
+		if err != nil {
+			t.Errorf("%s: %v", format, err)
+			continue
+		}
+
+		if len(stations) != 3 {
+			t.Errorf("%s: got %d stations, want 3", format, len(stations))
+			continue
+		}
+
+		one, two, three := stations[0], stations[1], stations[2]
+		if !slices.Equal(one.urls, []string{"http://one/", "http://one/b"}) || one.bitrate != 128 || !slices.Equal(one.tags, []string{"Pop"}) {
+			t.Errorf("%s: one = %+v", format, one)
+		}
+		if two.title != "Two" || !slices.Equal(two.tags, []string{"Jazz"}) {
+			t.Errorf("%s: two = %+v", format, two)
+		}
+		if !slices.Equal(three.tags, []string{"Jazz", "Jazz/Smooth"}) || three.volumeOffset != -5 {
+			t.Errorf("%s: three = %+v", format, three)
+		}
+	}
+}
+
+func TestDecodeStationsList(t *testing.T) {
+	for format, data := range map[string]string{
+		"json": `[{"title": "One", "url": "http://one/"}]`,
+		"yaml": "- title: One\n  url: http://one/\n",
+	} {
+		stations, err := decodeStations([]byte(data), format)
+		if err != nil || len(stations) != 1 || stations[0].url != "http://one/" {
+			t.Errorf("%s: stations = %+v, err = %v", format, stations, err)
+		}
+	}
+}
+
+func TestStationsMergesSources(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("USERPROFILE", dir)
+
+	team := filepath.Join(dir, "team.csv")
+	mine := filepath.Join(dir, "mine.yaml")
+	os.WriteFile(team, []byte("One,http://one/,Jazz\nTwo,http://two/,Rock\n"), 0644)
+	os.WriteFile(mine, []byte("- {title: My One, url: http://one/, tags: [Lounge]}\n- {title: Three, url: http://three/}\n"), 0644)
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	stations, notes := Stations(team, srv.URL+"/missing.csv", mine)
+	if len(notes) != 1 || !strings.Contains(notes[0], "missing.csv") {
+		t.Errorf("notes = %q, want one about the missing list", notes)
+	}
+
+	var titles []string
+	for _, s := range stations {
+		titles = append(titles, s.title)
+	}
+	if !slices.Equal(titles, []string{"One", "Two", "Three"}) {
+		t.Fatalf("stations = %q", titles)
+	}
+
+	if !slices.Equal(stations[0].tags, []string{"Jazz", "team", "Lounge", "mine"}) {
+		t.Errorf("merged tags = %q", stations[0].tags)
+	}
+	if !slices.Equal(stations[2].tags, []string{"mine"}) {
+		t.Errorf("tags = %q", stations[2].tags)
+	}
+
+	if stations, _ := Stations(srv.URL + "/missing.csv"); len(stations) == 0 {
+		t.Error("no default stations when the only source is missing")
+	}
+
+	if stations, _ := Stations(); len(stations) == 0 || slices.Contains(stations[0].tags, "Default") {
+		t.Error("the default stations alone must not be tagged by their source")
+	}
+}
+
+func TestStationsCache(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"title": "One", "url": "http://one/"}]`))
+	}))
+
+	list := srv.URL + "/stations"
+
+	for range 2 {
+		stations, notes := Stations(list)
+		if len(stations) != 1 || stations[0].title != "One" || len(notes) != 0 {
+			t.Fatalf("stations = %+v, notes = %q", stations, notes)
+		}
+	}
+
+	if !slices.Equal(requests, []string{"", `"v1"`}) {
+		t.Errorf("If-None-Match headers = %q, want the list revalidated", requests)
+	}
+
+	srv.Close()
+
+	stations, notes := Stations(list)
+	if len(stations) != 1 || stations[0].title != "One" {
+		t.Errorf("stations = %+v, want the cached copy", stations)
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "using the copy from") {
+		t.Errorf("notes = %q", notes)
+	}
+}
+
+func TestEditStationsFile(t *testing.T) {
+	dir := t.TempDir()
+	jazz := Station{title: "Jazz", url: "http://jazz/", urls: []string{"http://jazz/", "http://jazz2/"}, tags: []string{"Jazz", "Lounge"}}
+
+	tests := []struct {
+		name string
+		data string
+		url  string
+		st   *Station
+		want string
+	}{
+		{"add", "A,http://a/,Rock\n", "", &jazz,
+			"A,http://a/,Rock\nJazz,http://jazz/|http://jazz2/,Jazz;Lounge\n"},
+		{"add to a new file", "", "", &jazz,
+			"title,url,tags\nJazz,http://jazz/|http://jazz2/,Jazz;Lounge\n"},
+		{"edit", "Name,URL,Notes,Tags\nA,http://a/,keep,Rock\nB,http://b/,,Pop\n", "http://b/", &jazz,
+			"Name,URL,Notes,Tags\nA,http://a/,keep,Rock\nJazz,http://jazz/|http://jazz2/,,Jazz;Lounge\n"},
+		{"add a column", "title,url\nA,http://a/\n", "", &Station{title: "B", url: "http://b/", country: "LT", bitrate: 128},
+			"title,url,country,bitrate\nA,http://a/\nB,http://b/,LT,128\n"},
+		{"add a header", "A,http://a/,Rock,x,y\n", "", &Station{title: "B", url: "http://b/", uuid: "abc"},
+			"title,url,tags,extra1,extra2,uuid\nA,http://a/,Rock,x,y\nB,http://b/,,,,abc\n"},
+		{"delete", "A,http://a/,Rock\nB,http://b/,Pop\n", "http://a/", nil, "B,http://b/,Pop\n"},
+	}
+
+	for i, tt := range tests {
+		file := filepath.Join(dir, fmt.Sprintf("%d.csv", i))
+		if tt.data != "" {
+			os.WriteFile(file, []byte(tt.data), 0644)
+		}
+
+		if err := editStationsFile(file, tt.url, tt.st); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		if data, _ := os.ReadFile(file); string(data) != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, data, tt.want)
+		}
+	}
+
+	file := filepath.Join(dir, "0.csv")
+	if err := editStationsFile(file, "http://missing/", nil); !errors.Is(err, errNotInFile) {
+		t.Errorf("got %v, want %v", err, errNotInFile)
+	}
+
+	file = filepath.Join(dir, "stations.yaml")
+	os.WriteFile(file, []byte("- title: A\n  url: http://a/\n"), 0644)
+	if err := editStationsFile(file, "", &jazz); err == nil {
+		t.Error("editing a YAML file should fail")
+	}
+}
@@ -0,0 +1,438 @@
+package radio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+const tagsVendor = "goradion"
+
+var errNotTaggable = errors.New("unsupported file format")
+
+type trackTags struct {
+	Title   string
+	Artist  string
+	Station string
+}
+
+func newTrackTags(song, station string) trackTags {
+	t := trackTags{Title: song, Station: station}
+	if artist, title, ok := strings.Cut(song, " - "); ok {
+		t.Artist = strings.TrimSpace(artist)
+		t.Title = strings.TrimSpace(title)
+	}
+	return t
+}
+
+// writeTags tags a recorded file in place: ID3v2 for MP3 and AAC, Vorbis
+// comments for Ogg (Vorbis, Opus) and FLAC.
+func writeTags(path string, t trackTags) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3", ".aac":
+		data = tagID3(data, t)
+	case ".ogg", ".opus":
+		data, err = tagOgg(data, t)
+	case ".flac":
+		data, err = tagFLAC(data, t)
+	default:
+		err = errNotTaggable
+	}
+
+	if err != nil {
+		return fmt.Errorf("can't tag %s: %w", path, err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// tagID3 replaces an existing ID3v2 tag with a v2.3 one.
+func tagID3(data []byte, t trackTags) []byte {
+	if len(data) >= 10 && bytes.HasPrefix(data, []byte("ID3")) {
+		size := 10 + syncsafe(data[6:10])
+		if data[5]&0x10 != 0 {
+			size += 10 // footer
+		}
+		data = data[min(size, len(data)):]
+	}
+
+	var frames bytes.Buffer
+	for _, f := range []struct{ id, text string }{
+		{"TIT2", t.Title},
+		{"TPE1", t.Artist},
+		{"TALB", t.Station},
+		{"TRSN", t.Station},
+	} {
+		if f.text == "" {
+			continue
+		}
+
+		// UTF-16 with a byte order mark
+		body := []byte{0x01, 0xff, 0xfe}
+		for _, u := range utf16.Encode([]rune(f.text)) {
+			body = binary.LittleEndian.AppendUint16(body, u)
+		}
+
+		frames.WriteString(f.id)
+		frames.Write(binary.BigEndian.AppendUint32(nil, uint32(len(body))))
+		frames.Write([]byte{0, 0})
+		frames.Write(body)
+	}
+
+	tag := append([]byte{'I', 'D', '3', 3, 0, 0}, toSyncsafe(frames.Len())...)
+	tag = append(tag, frames.Bytes()...)
+
+	return append(tag, data...)
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
+
+func toSyncsafe(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+}
+
+// vorbisComments builds a comment list keeping the vendor and the comments
+// of the original list, except for the ones that are replaced.
+func vorbisComments(orig []byte, t trackTags) []byte {
+	vendor := tagsVendor
+	var kept []string
+
+	if vendorLen, rest, ok := readUint32LE(orig); ok && int(vendorLen) <= len(rest) {
+		vendor = string(rest[:vendorLen])
+		rest = rest[vendorLen:]
+
+		n, rest, _ := readUint32LE(rest)
+		for ; n > 0; n-- {
+			l, r, ok := readUint32LE(rest)
+			if !ok || int(l) > len(r) {
+				break
+			}
+			c := string(r[:l])
+			rest = r[l:]
+
+			key, _, _ := strings.Cut(c, "=")
+			switch strings.ToUpper(key) {
+			case "TITLE", "ARTIST", "ALBUM":
+			default:
+				kept = append(kept, c)
+			}
+		}
+	}
+
+	for _, c := range [][2]string{{"TITLE", t.Title}, {"ARTIST", t.Artist}, {"ALBUM", t.Station}} {
+		if c[1] != "" {
+			kept = append(kept, c[0]+"="+c[1])
+		}
+	}
+
+	b := binary.LittleEndian.AppendUint32(nil, uint32(len(vendor)))
+	b = append(b, vendor...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(kept)))
+	for _, c := range kept {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(c)))
+		b = append(b, c...)
+	}
+
+	return b
+}
+
+func readUint32LE(b []byte) (uint32, []byte, bool) {
+	if len(b) < 4 {
+		return 0, b, false
+	}
+	return binary.LittleEndian.Uint32(b), b[4:], true
+}
+
+// tagFLAC replaces or adds the VORBIS_COMMENT metadata block.
+func tagFLAC(data []byte, t trackTags) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte("fLaC")) {
+		return nil, errors.New("not a FLAC file")
+	}
+
+	type block struct {
+		kind byte
+		body []byte
+	}
+
+	var blocks []block
+	pos := 4
+
+	for {
+		if pos+4 > len(data) {
+			return nil, errors.New("truncated metadata")
+		}
+
+		last := data[pos]&0x80 != 0
+		kind := data[pos] & 0x7f
+		size := int(data[pos+1])<<16 | int(data[pos+2])<<8 | int(data[pos+3])
+		pos += 4
+
+		if pos+size > len(data) {
+			return nil, errors.New("truncated metadata")
+		}
+
+		blocks = append(blocks, block{kind, data[pos : pos+size]})
+		pos += size
+
+		if last {
+			break
+		}
+	}
+
+	const vorbisComment = 4
+
+	var orig []byte
+	out := []byte("fLaC")
+	var meta []block
+
+	for _, b := range blocks {
+		if b.kind == vorbisComment {
+			orig = b.body
+			continue
+		}
+		meta = append(meta, b)
+	}
+
+	// STREAMINFO must stay first
+	comments := block{vorbisComment, vorbisComments(orig, t)}
+	meta = append(meta[:1], append([]block{comments}, meta[1:]...)...)
+
+	for i, b := range meta {
+		header := b.kind
+		if i == len(meta)-1 {
+			header |= 0x80
+		}
+		out = append(out, header, byte(len(b.body)>>16), byte(len(b.body)>>8), byte(len(b.body)))
+		out = append(out, b.body...)
+	}
+
+	return append(out, data[pos:]...), nil
+}
+
+type oggPage struct {
+	headerType byte
+	granule    uint64
+	serial     uint32
+	seq        uint32
+	segments   []byte
+	data       []byte
+}
+
+// tagOgg rewrites the comment header of the first logical stream of an Ogg
+// Vorbis or Opus file, repaginating the headers and renumbering the pages
+// that follow them.
+func tagOgg(data []byte, t trackTags) ([]byte, error) {
+	var pages []oggPage
+
+	for pos := 0; pos < len(data); {
+		p, n, err := readOggPage(data[pos:])
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, p)
+		pos += n
+	}
+
+	if len(pages) == 0 || pages[0].headerType&0x02 == 0 {
+		return nil, errors.New("not an Ogg file")
+	}
+
+	serial := pages[0].serial
+
+	var headers int
+	var commentPrefix []byte
+
+	switch {
+	case bytes.HasPrefix(pages[0].data, []byte("\x01vorbis")):
+		headers = 3
+		commentPrefix = []byte("\x03vorbis")
+	case bytes.HasPrefix(pages[0].data, []byte("OpusHead")):
+		headers = 2
+		commentPrefix = []byte("OpusTags")
+	default:
+		return nil, errors.New("unsupported Ogg codec")
+	}
+
+	// Collect the header packets and find the first audio page.
+	var packets [][]byte
+	var packet []byte
+	first := -1
+
+	for i, p := range pages {
+		if p.serial != serial {
+			continue
+		}
+
+		if len(packets) == headers {
+			first = i
+			break
+		}
+
+		offset := 0
+		for _, lace := range p.segments {
+			packet = append(packet, p.data[offset:offset+int(lace)]...)
+			offset += int(lace)
+			if lace < 255 {
+				packets = append(packets, packet)
+				packet = nil
+			}
+		}
+	}
+
+	if len(packets) < headers || !bytes.HasPrefix(packets[1], commentPrefix) {
+		return nil, errors.New("malformed Ogg headers")
+	}
+
+	body := packets[1][len(commentPrefix):]
+	comment := append(append([]byte{}, commentPrefix...), vorbisComments(body, t)...)
+	if headers == 3 {
+		comment = append(comment, 1) // framing bit
+	}
+	packets[1] = comment
+
+	out := writeOggPage(nil, pages[0])
+	seq := pages[0].seq + 1
+
+	for _, p := range packets[1:] {
+		for _, hp := range paginate(p, serial, &seq) {
+			out = writeOggPage(out, hp)
+		}
+	}
+
+	if first < 0 {
+		first = len(pages)
+	}
+
+	for _, p := range pages[first:] {
+		if p.serial == serial {
+			p.seq = seq
+			seq++
+		}
+		out = writeOggPage(out, p)
+	}
+
+	return out, nil
+}
+
+func readOggPage(b []byte) (oggPage, int, error) {
+	if len(b) < 27 || !bytes.HasPrefix(b, []byte("OggS")) {
+		return oggPage{}, 0, errors.New("bad Ogg page")
+	}
+
+	nsegs := int(b[26])
+	if len(b) < 27+nsegs {
+		return oggPage{}, 0, errors.New("truncated Ogg page")
+	}
+
+	segments := b[27 : 27+nsegs]
+	size := 0
+	for _, s := range segments {
+		size += int(s)
+	}
+
+	end := 27 + nsegs + size
+	if len(b) < end {
+		return oggPage{}, 0, errors.New("truncated Ogg page")
+	}
+
+	return oggPage{
+		headerType: b[5],
+		granule:    binary.LittleEndian.Uint64(b[6:14]),
+		serial:     binary.LittleEndian.Uint32(b[14:18]),
+		seq:        binary.LittleEndian.Uint32(b[18:22]),
+		segments:   segments,
+		data:       b[27+nsegs : end],
+	}, end, nil
+}
+
+// paginate splits a header packet into pages of at most 255 segments.
+func paginate(packet []byte, serial uint32, seq *uint32) []oggPage {
+	var pages []oggPage
+	var headerType byte
+
+	for {
+		var segments []byte
+		n := 0
+
+		for len(segments) < 255 {
+			lace := min(len(packet)-n, 255)
+			segments = append(segments, byte(lace))
+			n += lace
+			if lace < 255 {
+				break
+			}
+		}
+
+		pages = append(pages, oggPage{
+			headerType: headerType,
+			serial:     serial,
+			seq:        *seq,
+			segments:   segments,
+			data:       packet[:n],
+		})
+		*seq++
+
+		packet = packet[n:]
+		if segments[len(segments)-1] < 255 {
+			return pages
+		}
+
+		headerType = 0x01 // continued packet
+	}
+}
+
+func writeOggPage(out []byte, p oggPage) []byte {
+	start := len(out)
+
+	out = append(out, 'O', 'g', 'g', 'S', 0, p.headerType)
+	out = binary.LittleEndian.AppendUint64(out, p.granule)
+	out = binary.LittleEndian.AppendUint32(out, p.serial)
+	out = binary.LittleEndian.AppendUint32(out, p.seq)
+	out = append(out, 0, 0, 0, 0, byte(len(p.segments)))
+	out = append(out, p.segments...)
+	out = append(out, p.data...)
+
+	binary.LittleEndian.PutUint32(out[start+22:], oggCRC(out[start:]))
+
+	return out
+}
+
+var oggCRCTable = func() (t [256]uint32) {
+	for i := range t {
+		r := uint32(i) << 24
+		for range 8 {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return t
+}()
+
+func oggCRC(b []byte) uint32 {
+	var crc uint32
+	for _, c := range b {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^c]
+	}
+	return crc
+}
@@ -0,0 +1,182 @@
+package radio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestOggCRC(t *testing.T) {
+	if crc := oggCRC([]byte("123456789")); crc != 0x89a1897f {
+		t.Errorf("crc = %#x, want 0x89a1897f", crc)
+	}
+}
+
+func TestNewTrackTags(t *testing.T) {
+	tags := newTrackTags("Some Artist - Some - Title", "Test FM")
+	if tags.Artist != "Some Artist" || tags.Title != "Some - Title" || tags.Station != "Test FM" {
+		t.Errorf("tags = %+v", tags)
+	}
+
+	tags = newTrackTags("Just a title", "Test FM")
+	if tags.Artist != "" || tags.Title != "Just a title" {
+		t.Errorf("tags = %+v", tags)
+	}
+}
+
+func TestTagID3(t *testing.T) {
+	audio := []byte{0xff, 0xfb, 0x90, 0x00}
+	old := append([]byte{'I', 'D', '3', 4, 0, 0}, toSyncsafe(3)...)
+	old = append(old, 1, 2, 3)
+
+	out := tagID3(append(old, audio...), trackTags{Title: "Title", Artist: "Artist", Station: "Test FM"})
+
+	if !bytes.HasPrefix(out, []byte{'I', 'D', '3', 3, 0}) {
+		t.Fatalf("no ID3v2.3 header: % x", out[:5])
+	}
+
+	size := syncsafe(out[6:10])
+	if !bytes.Equal(out[10+size:], audio) {
+		t.Errorf("audio after the tag = % x, want % x", out[10+size:], audio)
+	}
+
+	frames := out[10 : 10+size]
+	for _, id := range []string{"TIT2", "TPE1", "TALB", "TRSN"} {
+		if !bytes.Contains(frames, []byte(id)) {
+			t.Errorf("no %s frame", id)
+		}
+	}
+}
+
+// parseComments returns the comments of a Vorbis comment list.
+func parseComments(t *testing.T, b []byte) []string {
+	t.Helper()
+
+	vendorLen, b, _ := readUint32LE(b)
+	b = b[vendorLen:]
+
+	n, b, _ := readUint32LE(b)
+	var comments []string
+	for ; n > 0; n-- {
+		l, r, ok := readUint32LE(b)
+		if !ok || int(l) > len(r) {
+			t.Fatal("truncated comments")
+		}
+		comments = append(comments, string(r[:l]))
+		b = r[l:]
+	}
+
+	return comments
+}
+
+func TestTagOgg(t *testing.T) {
+	var seq uint32
+	var in []byte
+
+	head := oggPage{headerType: 0x02, serial: 7, seq: seq, segments: []byte{19}, data: make([]byte, 19)}
+	copy(head.data, "OpusHead")
+	in = writeOggPage(in, head)
+	seq++
+
+	tags := append([]byte("OpusTags"), vorbisComments(nil, trackTags{Title: "Old"})...)
+	for _, p := range paginate(tags, 7, &seq) {
+		in = writeOggPage(in, p)
+	}
+
+	audio := oggPage{granule: 960, serial: 7, seq: seq, segments: []byte{3}, data: []byte{1, 2, 3}}
+	in = writeOggPage(in, audio)
+
+	// A long title makes the comment header span several pages.
+	title := string(bytes.Repeat([]byte("x"), 70000))
+	out, err := tagOgg(in, trackTags{Title: title, Artist: "Artist", Station: "Test FM"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pages []oggPage
+	for pos := 0; pos < len(out); {
+		p, n, err := readOggPage(out[pos:])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		crc := binary.LittleEndian.Uint32(out[pos+22:])
+		page := slices.Clone(out[pos : pos+n])
+		binary.LittleEndian.PutUint32(page[22:], 0)
+		if oggCRC(page) != crc {
+			t.Errorf("page %d has a bad checksum", p.seq)
+		}
+
+		if p.seq != uint32(len(pages)) {
+			t.Errorf("page %d has sequence number %d", len(pages), p.seq)
+		}
+
+		pages = append(pages, p)
+		pos += n
+	}
+
+	if len(pages) < 4 {
+		t.Fatalf("%d pages, want the comment header to span several", len(pages))
+	}
+
+	var packet []byte
+	for _, p := range pages[1 : len(pages)-1] {
+		packet = append(packet, p.data...)
+	}
+
+	comments := parseComments(t, bytes.TrimPrefix(packet, []byte("OpusTags")))
+	want := []string{"TITLE=" + title, "ARTIST=Artist", "ALBUM=Test FM"}
+	if !slices.Equal(comments, want) {
+		t.Errorf("got %d comments, want %d", len(comments), len(want))
+	}
+
+	last := pages[len(pages)-1]
+	if last.granule != 960 || !bytes.Equal(last.data, audio.data) {
+		t.Errorf("audio page = %+v", last)
+	}
+}
+
+func TestTagFLAC(t *testing.T) {
+	in := []byte("fLaC")
+	in = append(in, 0x00, 0, 0, 2, 0xaa, 0xbb) // STREAMINFO
+	in = append(in, 0x84, 0, 0, 8, 0, 0, 0, 0, 0, 0, 0, 0)
+	in = append(in, 0xff, 0xf8)
+
+	out, err := tagFLAC(in, trackTags{Title: "Title", Station: "Test FM"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(out, []byte{'f', 'L', 'a', 'C', 0x00, 0, 0, 2, 0xaa, 0xbb}) {
+		t.Fatalf("STREAMINFO isn't first: % x", out[:10])
+	}
+
+	block := out[10:]
+	if block[0] != 0x84 {
+		t.Fatalf("block type = %#x, want the last VORBIS_COMMENT block", block[0])
+	}
+
+	size := int(block[1])<<16 | int(block[2])<<8 | int(block[3])
+	comments := parseComments(t, block[4:4+size])
+	if !slices.Equal(comments, []string{"TITLE=Title", "ALBUM=Test FM"}) {
+		t.Errorf("comments = %q", comments)
+	}
+
+	if !bytes.Equal(block[4+size:], []byte{0xff, 0xf8}) {
+		t.Error("audio frames weren't preserved")
+	}
+}
+
+func TestWriteTagsUnsupported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mka")
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeTags(path, trackTags{Title: "Title"}); err == nil {
+		t.Error("expected an error for a Matroska file")
+	}
+}